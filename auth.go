@@ -33,9 +33,10 @@ func (a *Accounts) BasicAuth(next HandleFunc) HandleFunc {
 
 func (a *Accounts) unAuthHandler(ctx *Context) {
 	if a.UnAuthHandler != nil {
+		ctx.Abort()
 		a.UnAuthHandler(ctx)
 	} else {
-		ctx.Writer.WriteHeader(http.StatusUnauthorized)
+		ctx.AbortWithStatus(http.StatusUnauthorized)
 	}
 }
 
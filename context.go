@@ -1,29 +1,39 @@
 package cob
 
 import (
+	"crypto/x509"
 	"errors"
-	"fmt"
 	"github.com/ljinfu/cob/binding"
 	"github.com/ljinfu/cob/log"
 	"github.com/ljinfu/cob/render"
 	"html/template"
 	"io"
+	"math"
 	"mime/multipart"
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 const defaultMaxMemory = 32 << 20 //32m
 
 type Context struct {
-	Writer     http.ResponseWriter
+	writermem  responseWriter
+	Writer     ResponseWriter
 	Request    *http.Request
 	engine     *Engine
 	queryCache url.Values
+	queryErr   error
 	formCache  url.Values
+	formErr    error
+	maxMemory  int64 //路由设置的 MaxMultipartMemory
+	params     Params
+	handlers   handlerChain
+	index      int //当前执行到调用链的位置
 
 	DisallowUnknownFields bool
 	IsInvalid             bool
@@ -36,28 +46,213 @@ type Context struct {
 	sameSite http.SameSite
 }
 
+//reset 清空上一个请求的状态，保留参数缓冲区以便复用
+func (c *Context) reset() {
+	c.writermem.reset(nil)
+	c.Writer = nil
+	c.Request = nil
+	c.queryCache = nil
+	c.queryErr = nil
+	c.formCache = nil
+	c.formErr = nil
+	c.maxMemory = 0
+	c.params = c.params[:0]
+	c.handlers = nil
+	c.index = -1
+	c.DisallowUnknownFields = false
+	c.IsInvalid = false
+	c.StatusCode = 0
+	c.Logger = nil
+	c.mu.Lock()
+	c.Keys = nil
+	c.mu.Unlock()
+	c.sameSite = 0
+}
+
+//Copy 返回当前请求状态的副本，可以在 handler 返回后交给其他协程使用(如 pool.Pool.Submit)，
+//副本的 Writer 会丢弃所有写入，响应只能在 handler 中通过原 Context 写入。
+//请求体在 handler 返回后会被关闭，因此表单在复制时解析
+func (c *Context) Copy() *Context {
+	c.initQueryCache()
+	c.initFormCache()
+	cp := c.clone()
+	cp.index = abortIndex
+	cp.writermem.reset(&discardResponseWriter{header: make(http.Header)})
+	if c.Writer != nil {
+		cp.writermem.status = c.Writer.Status()
+		cp.writermem.size = c.Writer.Size()
+	}
+	cp.Writer = &cp.writermem
+	return cp
+}
+
+//clone 复制请求状态，不会解析查询参数和表单，Writer 由调用方设置
+func (c *Context) clone() *Context {
+	cp := &Context{
+		Request:               c.Request,
+		engine:                c.engine,
+		queryCache:            c.queryCache,
+		queryErr:              c.queryErr,
+		formCache:             c.formCache,
+		formErr:               c.formErr,
+		maxMemory:             c.maxMemory,
+		params:                append(Params(nil), c.params...),
+		DisallowUnknownFields: c.DisallowUnknownFields,
+		IsInvalid:             c.IsInvalid,
+		StatusCode:            c.StatusCode,
+		Logger:                c.Logger,
+		sameSite:              c.sameSite,
+	}
+	c.mu.RLock()
+	if c.Keys != nil {
+		cp.Keys = make(map[string]interface{}, len(c.Keys))
+		for k, v := range c.Keys {
+			cp.Keys[k] = v
+		}
+	}
+	c.mu.RUnlock()
+	return cp
+}
+
+//调用链被终止后的位置
+const abortIndex = math.MaxInt32 >> 1
+
+//handle 从头执行调用链
+func (c *Context) handle(handlers handlerChain) {
+	c.handlers = handlers
+	c.index = -1
+	c.Next()
+}
+
+//Next 执行调用链中剩余的处理，只能在中间件中调用，与中间件的 next 参数等价
+func (c *Context) Next() {
+	c.index++
+	for c.index < len(c.handlers) {
+		c.handlers[c.index](c)
+		c.index++
+	}
+}
+
+//Abort 终止调用链，后续的中间件和 handler 不再执行，已经在执行的中间件继续执行完
+func (c *Context) Abort() {
+	c.index = abortIndex
+}
+
+func (c *Context) IsAborted() bool {
+	return c.index >= abortIndex
+}
+
+//AbortWithStatus 终止调用链并发送状态码
+func (c *Context) AbortWithStatus(code int) {
+	c.Abort()
+	c.Writer.WriteHeader(code)
+	c.Writer.WriteHeaderNow()
+	c.StatusCode = code
+}
+
+//AbortWithStatusJSON 终止调用链并返回 json
+func (c *Context) AbortWithStatusJSON(code int, value interface{}) error {
+	c.Abort()
+	return c.JSON(code, value)
+}
+
+//query 参数在第一次使用时解析
 func (c *Context) initQueryCache() {
+	if c.queryCache != nil {
+		return
+	}
 	if c.Request != nil {
-		c.queryCache = c.Request.URL.Query()
+		c.queryCache, c.queryErr = url.ParseQuery(c.Request.URL.RawQuery)
 	} else {
 		c.queryCache = url.Values{}
 	}
 }
 
+//表单在第一次使用时解析，非 multipart 请求只解析 urlencoded 表单
 func (c *Context) initFormCache() {
-	if c.Request != nil {
-		if err := c.Request.ParseMultipartForm(defaultMaxMemory); err != nil {
-			if errors.Is(err, http.ErrNotMultipart) {
-				fmt.Println(err)
-			}
-		}
+	if c.formCache != nil {
+		return
+	}
+	c.formCache = url.Values{}
+	if c.Request == nil {
+		return
+	}
+	//ParseMultipartForm 对非 multipart 请求只返回 ErrNotMultipart，先单独解析以获取表单错误
+	err := c.Request.ParseForm()
+	if err == nil {
+		err = c.Request.ParseMultipartForm(c.maxMultipartMemory())
+	}
+	if err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		c.formErr = err
+	}
+	if c.Request.PostForm != nil {
 		c.formCache = c.Request.PostForm
-	} else {
-		c.formCache = url.Values{}
+	}
+}
+
+//maxMultipartMemory 路由设置优先，其次为 Engine.MaxMultipartMemory
+func (c *Context) maxMultipartMemory() int64 {
+	if c.maxMemory > 0 {
+		return c.maxMemory
+	}
+	if c.engine != nil && c.engine.MaxMultipartMemory > 0 {
+		return c.engine.MaxMultipartMemory
+	}
+	return defaultMaxMemory
+}
+
+//QueryError 解析 query 参数的错误，解析出错时仍可以获取其余参数
+func (c *Context) QueryError() error {
+	c.initQueryCache()
+	return c.queryErr
+}
+
+//FormError 解析表单的错误，如请求体格式错误、读取失败，
+//表单包含 query 参数，query 格式错误时同样返回错误
+func (c *Context) FormError() error {
+	c.initFormCache()
+	return c.formErr
+}
+
+//路由参数 /get/:id
+func (c *Context) Param(key string) string {
+	return c.params.ByName(key)
+}
+
+func (c *Context) GetParam(key string) (string, bool) {
+	return c.params.Get(key)
+}
+
+func (c *Context) Params() Params {
+	return c.params
+}
+
+func (c *Context) ParamInt(key string) (int, error) {
+	return strconv.Atoi(c.Param(key))
+}
+
+func (c *Context) ParamInt64(key string) (int64, error) {
+	return strconv.ParseInt(c.Param(key), 10, 64)
+}
+
+func (c *Context) ParamUint64(key string) (uint64, error) {
+	return strconv.ParseUint(c.Param(key), 10, 64)
+}
+
+func (c *Context) ParamUUID(key string) (UUID, error) {
+	return ParseUUID(c.Param(key))
+}
+
+func (c *Context) unescapeParams() {
+	for i, p := range c.params {
+		if val, err := url.PathUnescape(p.Value); err == nil {
+			c.params[i].Value = val
+		}
 	}
 }
 
 func (c *Context) GetQuery(key string) string {
+	c.initQueryCache()
 	return c.queryCache.Get(key)
 }
 
@@ -70,16 +265,19 @@ func (c *Context) GetDefaultQuery(key, defaultVal string) string {
 }
 
 func (c *Context) GetQueryArray(key string) ([]string, bool) {
+	c.initQueryCache()
 	strings, ok := c.queryCache[key]
 	return strings, ok
 }
 
 func (c *Context) QueryArray(key string) []string {
+	c.initQueryCache()
 	return c.queryCache[key]
 }
 
 //http://xxx:xx/naa?user[id]=1&user[name]=a
 func (c *Context) GetQueryMap(key string) (map[string]string, bool) {
+	c.initQueryCache()
 	return c.get(c.queryCache, key)
 }
 
@@ -116,11 +314,13 @@ func (c *Context) PostFormArray(key string) []string {
 }
 
 func (c *Context) GetPostFormArray(key string) ([]string, bool) {
+	c.initFormCache()
 	vals, ok := c.formCache[key]
 	return vals, ok
 }
 
 func (c *Context) GetPostFormMap(key string) (map[string]string, bool) {
+	c.initFormCache()
 	return c.get(c.formCache, key)
 }
 
@@ -130,17 +330,26 @@ func (c *Context) PostFormMap(key string) map[string]string {
 }
 
 func (c *Context) FormFile(key string) (*multipart.FileHeader, error) {
-	file, header, err := c.Request.FormFile(key)
-	defer file.Close()
-	return header, err
+	files, err := c.FormFiles(key)
+	if err != nil {
+		return nil, err
+	}
+	return files[0], nil
 }
 
 func (c *Context) FormFiles(key string) ([]*multipart.FileHeader, error) {
 	form, err := c.MultipartForm()
-	return form.File[key], err
+	if err != nil {
+		return nil, err
+	}
+	files := form.File[key]
+	if len(files) == 0 {
+		return nil, http.ErrMissingFile
+	}
+	return files, nil
 }
 
-//将file 保存到dst
+//将file 保存到dst，dst 由调用方保证安全，使用客户端文件名时请用 SaveUploadedFile
 func (c *Context) TransferTo(file *multipart.FileHeader, dst string) error {
 	src, err := file.Open()
 	if err != nil {
@@ -158,8 +367,14 @@ func (c *Context) TransferTo(file *multipart.FileHeader, dst string) error {
 }
 
 func (c *Context) MultipartForm() (*multipart.Form, error) {
-	err := c.Request.ParseMultipartForm(defaultMaxMemory)
-	return c.Request.MultipartForm, err
+	c.initFormCache()
+	if c.formErr != nil {
+		return nil, c.formErr
+	}
+	if c.Request.MultipartForm == nil {
+		return nil, http.ErrNotMultipart
+	}
+	return c.Request.MultipartForm, nil
 }
 
 func (c *Context) BindJson(obj interface{}) error {
@@ -249,6 +464,15 @@ func (c *Context) Redirect(status int, url string) error {
 	})
 }
 
+//重定向到命名路由
+func (c *Context) RedirectToRoute(status int, name string, params ...interface{}) error {
+	location, err := c.engine.URL(name, params...)
+	if err != nil {
+		return err
+	}
+	return c.Redirect(status, location)
+}
+
 func (c *Context) String(status int, format string, val ...interface{}) error {
 	return c.Render(status, &render.String{Format: format, Data: val})
 }
@@ -284,6 +508,32 @@ func (c *Context) HandleWithError(statusCode int, obj interface{}, err error) {
 	c.JSON(statusCode, obj)
 }
 
+//ClientCertificate 双向TLS认证时客户端的证书，没有时返回nil
+func (c *Context) ClientCertificate() *x509.Certificate {
+	if c.Request.TLS == nil || len(c.Request.TLS.PeerCertificates) == 0 {
+		return nil
+	}
+	return c.Request.TLS.PeerCertificates[0]
+}
+
+//ClientIdentity 客户端证书的身份，依次取 CommonName、URI(SPIFFE ID)、DNS 名称
+func (c *Context) ClientIdentity() string {
+	cert := c.ClientCertificate()
+	if cert == nil {
+		return ""
+	}
+	if cert.Subject.CommonName != "" {
+		return cert.Subject.CommonName
+	}
+	if len(cert.URIs) > 0 {
+		return cert.URIs[0].String()
+	}
+	if len(cert.DNSNames) > 0 {
+		return cert.DNSNames[0]
+	}
+	return ""
+}
+
 func (c *Context) SetBasicAuth(username, password string) {
 	c.Request.SetBasicAuth(username, password)
 }
@@ -304,6 +554,42 @@ func (c *Context) Get(key string) (value interface{}, ok bool) {
 	return
 }
 
+//Deadline Done Err Value 实现 context.Context，基于 Request.Context()，
+//可以直接传给数据库等下游调用，客户端断开或超时后取消
+func (c *Context) Deadline() (deadline time.Time, ok bool) {
+	if c.Request == nil {
+		return
+	}
+	return c.Request.Context().Deadline()
+}
+
+func (c *Context) Done() <-chan struct{} {
+	if c.Request == nil {
+		return nil
+	}
+	return c.Request.Context().Done()
+}
+
+func (c *Context) Err() error {
+	if c.Request == nil {
+		return nil
+	}
+	return c.Request.Context().Err()
+}
+
+//Value 字符串 key 优先从 Keys 中查找，其次从 Request.Context() 中查找
+func (c *Context) Value(key interface{}) interface{} {
+	if k, ok := key.(string); ok {
+		if val, exists := c.Get(k); exists {
+			return val
+		}
+	}
+	if c.Request == nil {
+		return nil
+	}
+	return c.Request.Context().Value(key)
+}
+
 func (c *Context) SetCookie(name, value string, maxAge int, path, domain string, secure, httpOnly bool) {
 	if path == "" {
 		path = "/"
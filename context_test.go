@@ -0,0 +1,296 @@
+package cob
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestContextReset(t *testing.T) {
+	engine := New()
+	ctx := engine.allocateContext().(*Context)
+	ctx.writermem.reset(httptest.NewRecorder())
+	ctx.Writer = &ctx.writermem
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/user/1?name=cob", nil)
+	ctx.initQueryCache()
+	ctx.params = append(ctx.params, Param{Key: "id", Value: "1"})
+	ctx.Set("user", "cob")
+	ctx.SetSameSite(http.SameSiteStrictMode)
+	ctx.String(http.StatusCreated, "ok")
+
+	ctx.reset()
+	if _, ok := ctx.Get("user"); ok {
+		t.Errorf("Keys not cleared")
+	}
+	if ctx.StatusCode != 0 || ctx.sameSite != 0 {
+		t.Errorf("StatusCode = %d, sameSite = %d, want 0", ctx.StatusCode, ctx.sameSite)
+	}
+	if ctx.Writer != nil || ctx.Request != nil || ctx.queryCache != nil || len(ctx.params) != 0 {
+		t.Errorf("request state not cleared")
+	}
+	if cap(ctx.params) == 0 {
+		t.Errorf("params buffer not kept")
+	}
+}
+
+func TestContextKeysNotShared(t *testing.T) {
+	engine := New()
+	engine.Get("/set", func(ctx *Context) {
+		ctx.Set("user", "cob")
+		ctx.String(http.StatusOK, "ok")
+	})
+	engine.Get("/get", func(ctx *Context) {
+		if _, ok := ctx.Get("user"); ok {
+			ctx.String(http.StatusOK, "leaked")
+			return
+		}
+		ctx.String(http.StatusOK, "")
+	})
+	for i := 0; i < 10; i++ {
+		for _, path := range []string{"/set", "/get"} {
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			if path == "/get" && w.Body.String() != "" {
+				t.Fatalf("Keys of previous request visible")
+			}
+		}
+	}
+}
+
+func TestContextCopy(t *testing.T) {
+	engine := New()
+	copied := make(chan *Context, 1)
+	engine.Get("/user/:id", func(ctx *Context) {
+		ctx.Set("user", "cob")
+		copied <- ctx.Copy()
+		ctx.String(http.StatusOK, "ok")
+	})
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/user/1?name=cob", nil))
+
+	cp := <-copied
+	if v, _ := cp.Get("user"); v != "cob" {
+		t.Errorf("copy Get(user) = %v, want cob", v)
+	}
+	if cp.Param("id") != "1" || cp.GetQuery("name") != "cob" {
+		t.Errorf("copy Param(id) = %q, GetQuery(name) = %q", cp.Param("id"), cp.GetQuery("name"))
+	}
+	cp.String(http.StatusOK, "discarded")
+	if w.Body.String() != "ok" {
+		t.Errorf("body = %q, want %q", w.Body.String(), "ok")
+	}
+}
+
+func TestContextCopyZero(t *testing.T) {
+	cp := (&Context{}).Copy()
+	if cp.Writer.Status() != http.StatusOK || cp.Writer.Written() {
+		t.Errorf("copy Status() = %d, Written() = %v", cp.Writer.Status(), cp.Writer.Written())
+	}
+}
+
+func TestContextCopyForm(t *testing.T) {
+	engine := New()
+	copied := make(chan *Context, 1)
+	engine.Post("/user", func(ctx *Context) {
+		copied <- ctx.Copy()
+	})
+	srv := httptest.NewServer(engine)
+	defer srv.Close()
+	resp, err := http.PostForm(srv.URL+"/user", url.Values{"name": {"cob"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	//请求结束后请求体已关闭，副本仍能读取表单
+	cp := <-copied
+	if cp.PostForm("name") != "cob" || cp.FormError() != nil {
+		t.Errorf("copy PostForm(name) = %q, FormError() = %v", cp.PostForm("name"), cp.FormError())
+	}
+}
+
+func TestContextLazyParse(t *testing.T) {
+	engine := New()
+	engine.MaxMultipartMemory = 1 << 20
+	var req *http.Request
+	engine.Post("/skip", func(ctx *Context) {
+		req = ctx.Request
+	})
+	engine.Post("/form", func(ctx *Context) {
+		ctx.String(http.StatusOK, "%v|%v|%d", ctx.QueryError() != nil, ctx.FormError() != nil, ctx.maxMultipartMemory())
+	})
+	engine.Post("/upload", func(ctx *Context) {
+		ctx.String(http.StatusOK, "%s|%d", ctx.PostForm("name"), ctx.maxMultipartMemory())
+	}).MaxMultipartMemory(1024)
+
+	r := httptest.NewRequest(http.MethodPost, "/skip", strings.NewReader("name=cob"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	engine.ServeHTTP(httptest.NewRecorder(), r)
+	if req.PostForm != nil {
+		t.Errorf("form parsed for handler not using it")
+	}
+
+	for _, tt := range []struct {
+		path string
+		body string
+		want string
+	}{
+		{"/form?name=cob", "name=cob", "false|false|1048576"},
+		{"/form?name=%zz", "name=%zz", "true|true|1048576"},
+		{"/upload", "name=cob", "cob|1024"},
+	} {
+		r := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		if w.Body.String() != tt.want {
+			t.Errorf("POST %s body = %q, want %q", tt.path, w.Body.String(), tt.want)
+		}
+	}
+}
+
+func TestContextAbort(t *testing.T) {
+	engine := New()
+	var trace []string
+	engine.Use(func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			trace = append(trace, "global")
+			next(ctx)
+			if ctx.IsAborted() {
+				trace = append(trace, "aborted")
+			}
+		}
+	}, func(_ HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			trace = append(trace, "next")
+			ctx.Next()
+		}
+	})
+	deny := func(_ HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, map[string]string{"msg": "denied"})
+		}
+	}
+	silent := func(_ HandleFunc) HandleFunc {
+		return func(ctx *Context) {}
+	}
+	handler := func(ctx *Context) {
+		trace = append(trace, "handler")
+	}
+	engine.Get("/ok", handler)
+	engine.Get("/deny", handler, deny)
+	engine.Get("/silent", handler, silent)
+
+	for _, tt := range []struct {
+		path  string
+		code  int
+		trace string
+	}{
+		{"/ok", http.StatusOK, "global,next,handler"},
+		{"/deny", http.StatusUnauthorized, "global,next,aborted"},
+		{"/silent", http.StatusOK, "global,next,aborted"},
+	} {
+		trace = trace[:0]
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if got := strings.Join(trace, ","); w.Code != tt.code || got != tt.trace {
+			t.Errorf("GET %s = %d %q, want %d %q", tt.path, w.Code, got, tt.code, tt.trace)
+		}
+	}
+}
+
+func TestContextTimeout(t *testing.T) {
+	engine := New()
+	cancelled := make(chan error, 1)
+	var user interface{}
+	engine.Use(func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			next(ctx)
+			user, _ = ctx.Get("user")
+		}
+	})
+	engine.Get("/slow", func(ctx *Context) {
+		<-ctx.Done()
+		cancelled <- ctx.Err()
+		ctx.String(http.StatusOK, "late")
+	}, Timeout(20*time.Millisecond))
+	engine.Get("/fast", func(ctx *Context) {
+		ctx.Set("user", "cob")
+		var c context.Context = ctx
+		ctx.Writer.Header().Set("X-User", c.Value("user").(string))
+		ctx.String(http.StatusCreated, "fast")
+	}, Timeout(time.Second))
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("GET /slow code = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+	if err := <-cancelled; err != context.DeadlineExceeded {
+		t.Errorf("ctx.Err() = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	if w.Code != http.StatusCreated || w.Body.String() != "fast" || w.Header().Get("X-User") != "cob" {
+		t.Errorf("GET /fast = %d %q %q", w.Code, w.Body.String(), w.Header().Get("X-User"))
+	}
+	if user != "cob" {
+		t.Errorf("Keys set after Timeout = %v, want cob", user)
+	}
+}
+
+func TestContextTypedValues(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/?page=2&size=x&debug=on&since=2024-01-02&wait=1.5s",
+		strings.NewReader("age=18&price=9.9&admin=false"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	ctx := &Context{Request: r}
+
+	if n, err := ctx.QueryInt("page"); n != 2 || err != nil {
+		t.Errorf("QueryInt(page) = %d, %v", n, err)
+	}
+	if _, err := ctx.QueryInt("size"); err == nil {
+		t.Errorf("QueryInt(size) succeeded")
+	}
+	if _, err := ctx.QueryInt("none"); !errors.Is(err, ErrValueMissing) {
+		t.Errorf("QueryInt(none) = %v, want %v", err, ErrValueMissing)
+	}
+	if n := ctx.QueryIntDefault("size", 10); n != 10 {
+		t.Errorf("QueryIntDefault(size) = %d, want 10", n)
+	}
+	if b, err := ctx.QueryBool("debug"); !b || err != nil {
+		t.Errorf("QueryBool(debug) = %v, %v", b, err)
+	}
+	if d := ctx.QueryDurationDefault("wait", 0); d != 1500*time.Millisecond {
+		t.Errorf("QueryDurationDefault(wait) = %v", d)
+	}
+	if tm, err := ctx.QueryTime("since", "2006-01-02"); err != nil || tm.Day() != 2 {
+		t.Errorf("QueryTime(since) = %v, %v", tm, err)
+	}
+	if n := ctx.PostFormIntDefault("age", 0); n != 18 {
+		t.Errorf("PostFormIntDefault(age) = %d, want 18", n)
+	}
+	if f, err := ctx.PostFormFloat64("price"); f != 9.9 || err != nil {
+		t.Errorf("PostFormFloat64(price) = %v, %v", f, err)
+	}
+	if b := ctx.PostFormBoolDefault("admin", true); b {
+		t.Errorf("PostFormBoolDefault(admin) = true, want false")
+	}
+
+	ctx.Set("user", "cob")
+	ctx.Set("id", int64(7))
+	if ctx.GetString("user") != "cob" || ctx.GetInt64("id") != 7 || ctx.GetInt("id") != 0 {
+		t.Errorf("GetString/GetInt64/GetInt = %q %d %d", ctx.GetString("user"), ctx.GetInt64("id"), ctx.GetInt("id"))
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("MustGet(none) did not panic")
+		}
+	}()
+	ctx.MustGet("none")
+}
@@ -0,0 +1,356 @@
+package cob
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+//ErrValueMissing 类型转换的参数不存在
+var ErrValueMissing = errors.New("value is missing")
+
+//typedValue query、表单中的参数值，转换出错时错误包含来源和参数名
+type typedValue struct {
+	source string
+	key    string
+	val    string
+	ok     bool
+}
+
+func (v typedValue) wrap(err error) error {
+	return fmt.Errorf("%s %s: %w", v.source, v.key, err)
+}
+
+func (v typedValue) int() (int, error) {
+	n, err := v.parseInt(0)
+	return int(n), err
+}
+
+func (v typedValue) int64() (int64, error) {
+	return v.parseInt(64)
+}
+
+func (v typedValue) parseInt(bitSize int) (int64, error) {
+	if !v.ok {
+		return 0, v.wrap(ErrValueMissing)
+	}
+	n, err := strconv.ParseInt(v.val, 10, bitSize)
+	if err != nil {
+		return 0, v.wrap(err)
+	}
+	return n, nil
+}
+
+func (v typedValue) uint64() (uint64, error) {
+	if !v.ok {
+		return 0, v.wrap(ErrValueMissing)
+	}
+	n, err := strconv.ParseUint(v.val, 10, 64)
+	if err != nil {
+		return 0, v.wrap(err)
+	}
+	return n, nil
+}
+
+func (v typedValue) float64() (float64, error) {
+	if !v.ok {
+		return 0, v.wrap(ErrValueMissing)
+	}
+	f, err := strconv.ParseFloat(v.val, 64)
+	if err != nil {
+		return 0, v.wrap(err)
+	}
+	return f, nil
+}
+
+//bool 支持 1 t true 0 f false 等 strconv.ParseBool 的格式，以及 on off
+func (v typedValue) bool() (bool, error) {
+	if !v.ok {
+		return false, v.wrap(ErrValueMissing)
+	}
+	switch v.val {
+	case "on":
+		return true, nil
+	case "off":
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v.val)
+	if err != nil {
+		return false, v.wrap(err)
+	}
+	return b, nil
+}
+
+func (v typedValue) duration() (time.Duration, error) {
+	if !v.ok {
+		return 0, v.wrap(ErrValueMissing)
+	}
+	d, err := time.ParseDuration(v.val)
+	if err != nil {
+		return 0, v.wrap(err)
+	}
+	return d, nil
+}
+
+func (v typedValue) time(layout string) (time.Time, error) {
+	if !v.ok {
+		return time.Time{}, v.wrap(ErrValueMissing)
+	}
+	t, err := time.Parse(layout, v.val)
+	if err != nil {
+		return time.Time{}, v.wrap(err)
+	}
+	return t, nil
+}
+
+func (c *Context) query(key string) typedValue {
+	c.initQueryCache()
+	vals := c.queryCache[key]
+	if len(vals) == 0 {
+		return typedValue{source: "query", key: key}
+	}
+	return typedValue{source: "query", key: key, val: vals[0], ok: true}
+}
+
+func (c *Context) postForm(key string) typedValue {
+	val, ok := c.GetPostForm(key)
+	return typedValue{source: "form", key: key, val: val, ok: ok}
+}
+
+//QueryInt 参数不存在时返回 ErrValueMissing，格式错误时返回 strconv 的错误
+func (c *Context) QueryInt(key string) (int, error) {
+	return c.query(key).int()
+}
+
+//QueryIntDefault 参数不存在或格式错误时返回 defaultVal
+func (c *Context) QueryIntDefault(key string, defaultVal int) int {
+	if n, err := c.QueryInt(key); err == nil {
+		return n
+	}
+	return defaultVal
+}
+
+func (c *Context) QueryInt64(key string) (int64, error) {
+	return c.query(key).int64()
+}
+
+func (c *Context) QueryInt64Default(key string, defaultVal int64) int64 {
+	if n, err := c.QueryInt64(key); err == nil {
+		return n
+	}
+	return defaultVal
+}
+
+func (c *Context) QueryUint64(key string) (uint64, error) {
+	return c.query(key).uint64()
+}
+
+func (c *Context) QueryUint64Default(key string, defaultVal uint64) uint64 {
+	if n, err := c.QueryUint64(key); err == nil {
+		return n
+	}
+	return defaultVal
+}
+
+func (c *Context) QueryFloat64(key string) (float64, error) {
+	return c.query(key).float64()
+}
+
+func (c *Context) QueryFloat64Default(key string, defaultVal float64) float64 {
+	if f, err := c.QueryFloat64(key); err == nil {
+		return f
+	}
+	return defaultVal
+}
+
+func (c *Context) QueryBool(key string) (bool, error) {
+	return c.query(key).bool()
+}
+
+func (c *Context) QueryBoolDefault(key string, defaultVal bool) bool {
+	if b, err := c.QueryBool(key); err == nil {
+		return b
+	}
+	return defaultVal
+}
+
+//QueryDuration 使用 time.ParseDuration 解析，如 300ms 1h30m
+func (c *Context) QueryDuration(key string) (time.Duration, error) {
+	return c.query(key).duration()
+}
+
+func (c *Context) QueryDurationDefault(key string, defaultVal time.Duration) time.Duration {
+	if d, err := c.QueryDuration(key); err == nil {
+		return d
+	}
+	return defaultVal
+}
+
+//QueryTime 按 layout 解析时间，如 time.RFC3339 "2006-01-02"
+func (c *Context) QueryTime(key, layout string) (time.Time, error) {
+	return c.query(key).time(layout)
+}
+
+func (c *Context) QueryTimeDefault(key, layout string, defaultVal time.Time) time.Time {
+	if t, err := c.QueryTime(key, layout); err == nil {
+		return t
+	}
+	return defaultVal
+}
+
+func (c *Context) PostFormInt(key string) (int, error) {
+	return c.postForm(key).int()
+}
+
+func (c *Context) PostFormIntDefault(key string, defaultVal int) int {
+	if n, err := c.PostFormInt(key); err == nil {
+		return n
+	}
+	return defaultVal
+}
+
+func (c *Context) PostFormInt64(key string) (int64, error) {
+	return c.postForm(key).int64()
+}
+
+func (c *Context) PostFormInt64Default(key string, defaultVal int64) int64 {
+	if n, err := c.PostFormInt64(key); err == nil {
+		return n
+	}
+	return defaultVal
+}
+
+func (c *Context) PostFormUint64(key string) (uint64, error) {
+	return c.postForm(key).uint64()
+}
+
+func (c *Context) PostFormUint64Default(key string, defaultVal uint64) uint64 {
+	if n, err := c.PostFormUint64(key); err == nil {
+		return n
+	}
+	return defaultVal
+}
+
+func (c *Context) PostFormFloat64(key string) (float64, error) {
+	return c.postForm(key).float64()
+}
+
+func (c *Context) PostFormFloat64Default(key string, defaultVal float64) float64 {
+	if f, err := c.PostFormFloat64(key); err == nil {
+		return f
+	}
+	return defaultVal
+}
+
+func (c *Context) PostFormBool(key string) (bool, error) {
+	return c.postForm(key).bool()
+}
+
+func (c *Context) PostFormBoolDefault(key string, defaultVal bool) bool {
+	if b, err := c.PostFormBool(key); err == nil {
+		return b
+	}
+	return defaultVal
+}
+
+func (c *Context) PostFormDuration(key string) (time.Duration, error) {
+	return c.postForm(key).duration()
+}
+
+func (c *Context) PostFormDurationDefault(key string, defaultVal time.Duration) time.Duration {
+	if d, err := c.PostFormDuration(key); err == nil {
+		return d
+	}
+	return defaultVal
+}
+
+func (c *Context) PostFormTime(key, layout string) (time.Time, error) {
+	return c.postForm(key).time(layout)
+}
+
+func (c *Context) PostFormTimeDefault(key, layout string, defaultVal time.Time) time.Time {
+	if t, err := c.PostFormTime(key, layout); err == nil {
+		return t
+	}
+	return defaultVal
+}
+
+//MustGet 获取 Set 设置的值，不存在时 panic
+func (c *Context) MustGet(key string) interface{} {
+	if value, ok := c.Get(key); ok {
+		return value
+	}
+	panic(fmt.Sprintf("key \"%s\" does not exist", key))
+}
+
+//GetString 等方法在值不存在或类型不匹配时返回零值
+func (c *Context) GetString(key string) (s string) {
+	if val, ok := c.Get(key); ok && val != nil {
+		s, _ = val.(string)
+	}
+	return
+}
+
+func (c *Context) GetBool(key string) (b bool) {
+	if val, ok := c.Get(key); ok && val != nil {
+		b, _ = val.(bool)
+	}
+	return
+}
+
+func (c *Context) GetInt(key string) (i int) {
+	if val, ok := c.Get(key); ok && val != nil {
+		i, _ = val.(int)
+	}
+	return
+}
+
+func (c *Context) GetInt64(key string) (i int64) {
+	if val, ok := c.Get(key); ok && val != nil {
+		i, _ = val.(int64)
+	}
+	return
+}
+
+func (c *Context) GetUint64(key string) (i uint64) {
+	if val, ok := c.Get(key); ok && val != nil {
+		i, _ = val.(uint64)
+	}
+	return
+}
+
+func (c *Context) GetFloat64(key string) (f float64) {
+	if val, ok := c.Get(key); ok && val != nil {
+		f, _ = val.(float64)
+	}
+	return
+}
+
+func (c *Context) GetDuration(key string) (d time.Duration) {
+	if val, ok := c.Get(key); ok && val != nil {
+		d, _ = val.(time.Duration)
+	}
+	return
+}
+
+func (c *Context) GetTime(key string) (t time.Time) {
+	if val, ok := c.Get(key); ok && val != nil {
+		t, _ = val.(time.Time)
+	}
+	return
+}
+
+func (c *Context) GetStringSlice(key string) (ss []string) {
+	if val, ok := c.Get(key); ok && val != nil {
+		ss, _ = val.([]string)
+	}
+	return
+}
+
+func (c *Context) GetStringMap(key string) (sm map[string]interface{}) {
+	if val, ok := c.Get(key); ok && val != nil {
+		sm, _ = val.(map[string]interface{})
+	}
+	return
+}
@@ -1,12 +1,15 @@
 package cob
 
 import (
-	"fmt"
 	coblog "github.com/ljinfu/cob/log"
 	"github.com/ljinfu/cob/render"
 	"html/template"
 	"net/http"
+	"net/url"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 )
 
 type ErrorHandler func(err error) (int, interface{})
@@ -21,13 +24,73 @@ type Engine struct {
 
 	Middles    []MiddlewareFunc
 	errHandler ErrorHandler
+
+	namedRoutes map[string]*Route
+
+	noRoute       HandleFunc
+	noMethod      HandleFunc
+	noRouteChain  handlerChain //经过全局中间件编译后的404处理
+	noMethodChain handlerChain //经过全局中间件编译后的405处理
+	optionsChain  handlerChain //经过全局中间件编译后的自动OPTIONS处理
+
+	//路径匹配但method不匹配时返回405并设置Allow头，否则返回404
+	HandleMethodNotAllowed bool
+	//自动响应OPTIONS请求，Allow头列出路径支持的method，会经过全局中间件
+	HandleOptions bool
+	//HEAD请求没有对应路由时使用GET路由处理，并丢弃响应体
+	HandleHead bool
+	//路由不存在但添加或去掉末尾的 / 后存在时重定向，GET为301，其他为308
+	RedirectTrailingSlash bool
+	//路由不存在时清理路径(// ../)并忽略大小写查找，存在时重定向
+	RedirectFixedPath bool
+	//使用 URL.RawPath 匹配，路径参数中可以包含编码后的 /
+	UseRawPath bool
+	//UseRawPath 时对路径参数进行解码
+	UnescapePathValues bool
+
+	//http.Server 超时配置，为0表示不限制
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	//收到信号后等待请求处理完成的最长时间，为0表示一直等待
+	ShutdownTimeout time.Duration
+	//非TLS连接支持 HTTP/2 (h2c)，用于服务网格内部通信
+	UseH2C bool
+	//解析 multipart 表单时最多使用的内存，超出的文件保存到临时文件，路由可以单独设置
+	MaxMultipartMemory int64
+	//热重启时等待新进程就绪的最长时间，为0表示一直等待
+	RestartTimeout time.Duration
+
+	servers      []*http.Server
+	listeners    []serveListener
+	serverLock   sync.Mutex
+	closed       bool
+	onStart      []func()
+	onShutdown   []func()
+	startOnce    sync.Once
+	shutdownOnce sync.Once
+	routesOnce   sync.Once //同时运行多个服务时路由表只打印一次
+	done         chan struct{}
 }
 
 func New() *Engine {
 	engine := &Engine{
-		Router: Router{},
+		Router:                 Router{},
+		HandleMethodNotAllowed: true,
+		HandleOptions:          true,
+		HandleHead:             true,
+		RedirectTrailingSlash:  true,
+		UnescapePathValues:     true,
+		ShutdownTimeout:        10 * time.Second,
+		RestartTimeout:         30 * time.Second,
+		MaxMultipartMemory:     defaultMaxMemory,
+		done:                   make(chan struct{}),
 	}
 	engine.Router.engin = engine
+	engine.RouterGroup = newRouterGroup(engine, nil, "")
+	engine.addGroup(engine.RouterGroup)
+	engine.rebuildNoRoute()
 	engine.pool.New = func() interface{} {
 		return engine.allocateContext()
 	}
@@ -46,58 +109,278 @@ func (e *Engine) allocateContext() interface{} {
 	return &Context{engine: e}
 }
 
-func (e *Engine) Run(addr string) error {
-	return http.ListenAndServe(addr, e)
-}
-
-func (e *Engine) RunTLS(addr, certFile, keyFile string) error {
-	return http.ListenAndServeTLS(addr, certFile, keyFile, e)
-}
-
 func (e *Engine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx := e.pool.Get().(*Context)
-	ctx.Writer = w
+	ctx.writermem.reset(w)
+	ctx.Writer = &ctx.writermem
 	ctx.Request = r
 	ctx.Logger = e.Logger
-	//初始化query参数
-	ctx.initQueryCache()
-	ctx.initFormCache()
 
-	e.httpRequestHandle(ctx, w, r)
+	e.httpRequestHandle(ctx, r)
+	ctx.Writer.WriteHeaderNow()
+	//放回池中前清空请求状态，handler 返回后仍要使用 Context 的协程需要先 Copy
+	ctx.reset()
 	e.pool.Put(ctx)
 }
 
-func (e *Engine) httpRequestHandle(ctx *Context, w http.ResponseWriter, r *http.Request) {
+func (e *Engine) httpRequestHandle(ctx *Context, r *http.Request) {
 	method := r.Method
+	path := r.URL.Path
+	unescape := false
+	if e.UseRawPath && r.URL.RawPath != "" {
+		path = r.URL.RawPath
+		unescape = e.UnescapePathValues
+	}
+	if handlers, ok := e.find(ctx, path, method); ok {
+		if unescape {
+			ctx.unescapeParams()
+		}
+		ctx.handle(handlers)
+		return
+	}
+	if method == http.MethodHead && e.HandleHead {
+		if handlers, ok := e.find(ctx, path, http.MethodGet); ok {
+			if unescape {
+				ctx.unescapeParams()
+			}
+			ctx.Writer = &headResponseWriter{ResponseWriter: ctx.Writer}
+			ctx.handle(handlers)
+			return
+		}
+	}
+	if method != http.MethodConnect && path != "/" {
+		if e.RedirectTrailingSlash && e.redirectTrailingSlash(ctx, path) {
+			return
+		}
+		if e.RedirectFixedPath && e.redirectFixedPath(ctx, path) {
+			return
+		}
+	}
+	if allowed := e.allowedMethods(r, path); len(allowed) > 0 {
+		if method == http.MethodOptions && e.HandleOptions {
+			ctx.Writer.Header().Set("Allow", strings.Join(allowed, ", "))
+			ctx.handle(e.optionsChain)
+			return
+		}
+		if e.HandleMethodNotAllowed {
+			ctx.Writer.Header().Set("Allow", strings.Join(allowed, ", "))
+			ctx.handle(e.noMethodChain)
+			return
+		}
+	}
+	ctx.handle(e.noRouteChain)
+}
+
+//NoRoute 设置404处理，会经过全局中间件
+func (e *Engine) NoRoute(handler HandleFunc) {
+	e.noRoute = handler
+	e.rebuildNoRoute()
+}
+
+//NoMethod 设置405处理，会经过全局中间件，Allow头已经设置
+func (e *Engine) NoMethod(handler HandleFunc) {
+	e.noMethod = handler
+	e.rebuildNoRoute()
+}
+
+func (e *Engine) rebuildNoRoute() {
+	noRoute := e.noRoute
+	if noRoute == nil {
+		noRoute = defaultNoRoute
+	}
+	noMethod := e.noMethod
+	if noMethod == nil {
+		noMethod = defaultNoMethod
+	}
+	e.noRouteChain = e.buildChain(noRoute)
+	e.noMethodChain = e.buildChain(noMethod)
+	e.optionsChain = e.buildChain(defaultOptions)
+}
+
+//全局中间件
+func (e *Engine) buildChain(handleFunc HandleFunc) handlerChain {
+	chain := make(handlerChain, 0, len(e.Middles)+1)
+	for _, middle := range e.Middles {
+		chain = append(chain, middle.adapt())
+	}
+	return append(chain, handleFunc)
+}
+
+func defaultNoRoute(ctx *Context) {
+	ctx.String(http.StatusNotFound, "%s %s not found \n ", ctx.Request.RequestURI, ctx.Request.Method)
+}
+
+func defaultNoMethod(ctx *Context) {
+	ctx.String(http.StatusMethodNotAllowed, "%s %s not allowed \n ", ctx.Request.RequestURI, ctx.Request.Method)
+}
+
+//defaultOptions 自动OPTIONS响应，Allow头已经设置
+func defaultOptions(ctx *Context) {
+	ctx.Writer.WriteHeader(http.StatusNoContent)
+	ctx.StatusCode = http.StatusNoContent
+}
+
+//find 在所有路由组中查找路由，路由参数写入ctx
+func (e *Engine) find(ctx *Context, path, method string) (handlerChain, bool) {
 	for _, group := range e.groups {
-		routerName := SubStringLast(r.URL.Path, "/"+group.name)
-		node := group.treeNode.Get(routerName)
-		if node != nil && node.isEnd {
-			handler, ok := group.handleFuncMap[node.routerName][ANY]
-			if ok {
-				group.MethodHandle(node.routerName, ANY, ctx, handler)
-				return
+		if !group.matchRequest(ctx.Request) {
+			continue
+		}
+		routerName, ok := group.trimPrefix(path)
+		if !ok {
+			continue
+		}
+		match, ok := group.lookup(routerName, method, ctx.params)
+		if !ok || match.handlers == nil {
+			//其他路由组可能有匹配的method
+			continue
+		}
+		ctx.params = group.hostParams(ctx.Request, match.params)
+		return match.handlers, true
+	}
+	return nil, false
+}
+
+//routeExists 路径和method是否有对应路由
+func (e *Engine) routeExists(r *http.Request, path, method string) bool {
+	for _, group := range e.groups {
+		if !group.matchRequest(r) {
+			continue
+		}
+		routerName, ok := group.trimPrefix(path)
+		if !ok {
+			continue
+		}
+		match, ok := group.lookup(routerName, method, nil)
+		if ok && match.handlers != nil {
+			return true
+		}
+	}
+	if method == http.MethodHead && e.HandleHead {
+		return e.routeExists(r, path, http.MethodGet)
+	}
+	return false
+}
+
+func (e *Engine) redirectTrailingSlash(ctx *Context, path string) bool {
+	if strings.HasSuffix(path, "/") {
+		path = path[:len(path)-1]
+	} else {
+		path += "/"
+	}
+	if !e.routeExists(ctx.Request, path, ctx.Request.Method) {
+		return false
+	}
+	e.redirect(ctx, path)
+	return true
+}
+
+func (e *Engine) redirectFixedPath(ctx *Context, path string) bool {
+	cleaned := cleanPath(path)
+	candidates := []string{cleaned}
+	if e.RedirectTrailingSlash {
+		if strings.HasSuffix(cleaned, "/") {
+			candidates = append(candidates, cleaned[:len(cleaned)-1])
+		} else {
+			candidates = append(candidates, cleaned+"/")
+		}
+	}
+	for _, candidate := range candidates {
+		for _, group := range e.groups {
+			if !group.matchRequest(ctx.Request) {
+				continue
 			}
-			//method 匹配
-			handler, ok = group.handleFuncMap[node.routerName][method]
-			if ok {
-				group.MethodHandle(node.routerName, method, ctx, handler)
-				return
+			fixed, ok := group.fixedPath(candidate)
+			if ok && fixed != path && e.routeExists(ctx.Request, fixed, ctx.Request.Method) {
+				e.redirect(ctx, fixed)
+				return true
 			}
-			w.WriteHeader(http.StatusMethodNotAllowed)
-			fmt.Fprintf(w, "%s %s not allowed \n ", r.RequestURI, method)
-			return
 		}
 	}
-	w.WriteHeader(http.StatusNotFound)
-	fmt.Fprintf(w, "%s %s not found \n ", r.RequestURI, method)
+	return false
 }
 
+func (e *Engine) redirect(ctx *Context, path string) {
+	r := ctx.Request
+	code := http.StatusMovedPermanently
+	if r.Method != http.MethodGet {
+		code = http.StatusPermanentRedirect
+	}
+	path = e.escapedPath(r, path)
+	if r.URL.RawQuery != "" {
+		path += "?" + r.URL.RawQuery
+	}
+	http.Redirect(ctx.Writer, r, path, code)
+	ctx.StatusCode = code
+}
+
+//escapedPath 重新编码重定向路径，避免解码后的 ? # \ 等字符改变重定向的目标
+func (e *Engine) escapedPath(r *http.Request, path string) string {
+	u := &url.URL{Path: path}
+	if e.UseRawPath && r.URL.RawPath != "" {
+		if unescaped, err := url.PathUnescape(path); err == nil {
+			u.Path, u.RawPath = unescaped, path
+		}
+	}
+	escaped := u.EscapedPath()
+	//以 // 开头的地址会被浏览器当作其他主机
+	if strings.HasPrefix(escaped, "//") {
+		escaped = "/%2F" + escaped[2:]
+	}
+	return escaped
+}
+
+//allowedMethods 路径支持的所有method，用于Allow头
+func (e *Engine) allowedMethods(r *http.Request, path string) []string {
+	methods := make(map[string]bool)
+	for _, group := range e.groups {
+		if !group.matchRequest(r) {
+			continue
+		}
+		routerName, ok := group.trimPrefix(path)
+		if !ok {
+			continue
+		}
+		match, ok := group.lookup(routerName, "", nil)
+		if !ok {
+			continue
+		}
+		for method := range group.handleFuncMap[match.routerName] {
+			methods[method] = true
+		}
+	}
+	if len(methods) == 0 {
+		return nil
+	}
+	if methods[http.MethodGet] && e.HandleHead {
+		methods[http.MethodHead] = true
+	}
+	if e.HandleOptions {
+		methods[http.MethodOptions] = true
+	}
+	allowed := make([]string, 0, len(methods))
+	for method := range methods {
+		if method != ANY {
+			allowed = append(allowed, method)
+		}
+	}
+	sort.Strings(allowed)
+	return allowed
+}
+
+//SetFuncMap 设置模板函数，默认包含 url 函数: {{url "user.info" "id" 1}}
 func (e *Engine) SetFuncMap(funcMap template.FuncMap) {
-	e.funcMap = funcMap
+	e.funcMap = make(template.FuncMap, len(funcMap)+1)
+	e.funcMap["url"] = e.URL
+	for name, fn := range funcMap {
+		e.funcMap[name] = fn
+	}
 }
 
 func (e *Engine) LoadTemplate(pattern string) {
+	if e.funcMap == nil {
+		e.SetFuncMap(nil)
+	}
 	t := template.Must(template.New("").Funcs(e.funcMap).ParseGlob(pattern))
 	e.SetHtmlTemplate(t)
 }
@@ -108,6 +391,8 @@ func (e *Engine) SetHtmlTemplate(t *template.Template) {
 
 func (e *Engine) Use(handleFunc ...MiddlewareFunc) {
 	e.Middles = append(e.Middles, handleFunc...)
+	e.RouterGroup.recompile()
+	e.rebuildNoRoute()
 }
 
 func (e *Engine) RegistryErrHandler(handler ErrorHandler) {
@@ -0,0 +1,519 @@
+package cob
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+//并发请求测试，配合 go test -race 使用
+func newRaceEngine() *Engine {
+	engine := New()
+	user := engine.Group("user")
+	routeMiddle := func(name string) MiddlewareFunc {
+		return func(next HandleFunc) HandleFunc {
+			return func(ctx *Context) {
+				ctx.Writer.Header().Set("X-Route", name)
+				next(ctx)
+			}
+		}
+	}
+	user.Get("/get/:id", func(ctx *Context) {
+		fmt.Fprintf(ctx.Writer, "id=%s", ctx.Param("id"))
+	}, routeMiddle("/get/:id"))
+	user.Get("/get/aa", func(ctx *Context) {
+		fmt.Fprint(ctx.Writer, "aa")
+	}, routeMiddle("/get/aa"))
+	user.Get("/static/*filepath", func(ctx *Context) {
+		fmt.Fprintf(ctx.Writer, "file=%s", ctx.Param("filepath"))
+	}, routeMiddle("/static/*filepath"))
+	return engine
+}
+
+func TestEngineConcurrentRequests(t *testing.T) {
+	engine := newRaceEngine()
+	tests := []struct {
+		path  string
+		body  string
+		route string
+	}{
+		{"/user/get/1", "id=1", "/get/:id"},
+		{"/user/get/aa", "aa", "/get/aa"},
+		{"/user/get/bb", "id=bb", "/get/:id"},
+		{"/user/static/css/app.css", "file=css/app.css", "/static/*filepath"},
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 100*len(tests))
+	for i := 0; i < 100; i++ {
+		for _, tt := range tests {
+			wg.Add(1)
+			go func(path, body, route string) {
+				defer wg.Done()
+				w := httptest.NewRecorder()
+				engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+				if w.Body.String() != body {
+					errs <- fmt.Errorf("GET %s body = %q, want %q", path, w.Body.String(), body)
+				}
+				if got := w.Header().Get("X-Route"); got != route {
+					errs <- fmt.Errorf("GET %s route = %q, want %q", path, got, route)
+				}
+			}(tt.path, tt.body, tt.route)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestEngineConcurrentNotFound(t *testing.T) {
+	engine := newRaceEngine()
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/user/none", nil))
+			if w.Code != http.StatusNotFound {
+				t.Errorf("GET /user/none code = %d, want %d", w.Code, http.StatusNotFound)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/user/get/1", nil))
+			if w.Code != http.StatusMethodNotAllowed {
+				t.Errorf("POST /user/get/1 code = %d, want %d", w.Code, http.StatusMethodNotAllowed)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func newBenchEngine() (*Engine, *RouterGroup) {
+	engine := New()
+	user := engine.Group("user")
+	middle := func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			next(ctx)
+		}
+	}
+	user.Use(middle, middle)
+	user.Get("/get/:id", func(ctx *Context) {}, middle)
+	return engine, user
+}
+
+//编译好的调用链: 查找 + 一次调用
+func BenchmarkEngineDispatch(b *testing.B) {
+	engine, _ := newBenchEngine()
+	r := httptest.NewRequest(http.MethodGet, "/user/get/1", nil)
+	w := httptest.NewRecorder()
+	ctx := &Context{engine: engine, Request: r}
+	ctx.writermem.reset(w)
+	ctx.Writer = &ctx.writermem
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		engine.httpRequestHandle(ctx, r)
+	}
+}
+
+//每次请求重新包装中间件
+func BenchmarkMethodHandle(b *testing.B) {
+	_, user := newBenchEngine()
+	r := httptest.NewRequest(http.MethodGet, "/user/get/1", nil)
+	w := httptest.NewRecorder()
+	ctx := &Context{Request: r}
+	ctx.writermem.reset(w)
+	ctx.Writer = &ctx.writermem
+	handler := user.handleFuncMap["/get/:id"][http.MethodGet]
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		match, _ := user.lookup("/get/1", http.MethodGet, ctx.params)
+		ctx.params = match.params
+		user.MethodHandle(match.routerName, match.method, ctx, handler)
+	}
+}
+
+func TestEngineGroup(t *testing.T) {
+	engine := New()
+	var trace []string
+	middle := func(name string) MiddlewareFunc {
+		return func(next HandleFunc) HandleFunc {
+			return func(ctx *Context) {
+				trace = append(trace, name)
+				next(ctx)
+			}
+		}
+	}
+	write := func(body string) HandleFunc {
+		return func(ctx *Context) {
+			fmt.Fprint(ctx.Writer, body)
+		}
+	}
+	engine.Get("/ping", write("pong"))
+	engine.Get("/admin/user/x", write("admin"))
+
+	user := engine.Group("user")
+	user.Use(middle("user"))
+	user.Get("/x", write("user"))
+
+	v1 := user.Group("/v1/")
+	v1.Use(middle("v1"))
+	v1.Get("/x", write("v1"))
+
+	tests := []struct {
+		path  string
+		code  int
+		body  string
+		trace string
+	}{
+		{"/ping", http.StatusOK, "pong", ""},
+		{"/admin/user/x", http.StatusOK, "admin", ""},
+		{"/user/x", http.StatusOK, "user", "user"},
+		{"/user/v1/x", http.StatusOK, "v1", "user,v1"},
+		{"/username/x", http.StatusNotFound, "", ""},
+		{"/v1/x", http.StatusNotFound, "", ""},
+	}
+	for _, tt := range tests {
+		trace = trace[:0]
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.code {
+			t.Errorf("GET %s code = %d, want %d", tt.path, w.Code, tt.code)
+		}
+		if tt.code == http.StatusOK && w.Body.String() != tt.body {
+			t.Errorf("GET %s body = %q, want %q", tt.path, w.Body.String(), tt.body)
+		}
+		if got := strings.Join(trace, ","); got != tt.trace {
+			t.Errorf("GET %s middlewares = %q, want %q", tt.path, got, tt.trace)
+		}
+	}
+}
+
+func TestEngineMethodNotAllowed(t *testing.T) {
+	engine := New()
+	user := engine.Group("user")
+	user.Get("/info", func(ctx *Context) {
+		fmt.Fprint(ctx.Writer, "info")
+	})
+	user.Post("/info", func(ctx *Context) {})
+	engine.Delete("/user/info", func(ctx *Context) {})
+
+	tests := []struct {
+		method string
+		code   int
+		allow  string
+		body   string
+	}{
+		{http.MethodPut, http.StatusMethodNotAllowed, "DELETE, GET, HEAD, OPTIONS, POST", ""},
+		{http.MethodOptions, http.StatusNoContent, "DELETE, GET, HEAD, OPTIONS, POST", ""},
+		{http.MethodHead, http.StatusOK, "", ""},
+		{http.MethodDelete, http.StatusOK, "", ""},
+		{http.MethodGet, http.StatusOK, "", "info"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(tt.method, "/user/info", nil))
+		if w.Code != tt.code {
+			t.Errorf("%s /user/info code = %d, want %d", tt.method, w.Code, tt.code)
+		}
+		if got := w.Header().Get("Allow"); got != tt.allow {
+			t.Errorf("%s /user/info Allow = %q, want %q", tt.method, got, tt.allow)
+		}
+		if tt.body != "" && w.Body.String() != tt.body {
+			t.Errorf("%s /user/info body = %q, want %q", tt.method, w.Body.String(), tt.body)
+		}
+		if tt.method == http.MethodHead && w.Body.Len() != 0 {
+			t.Errorf("HEAD /user/info body = %q, want empty", w.Body.String())
+		}
+	}
+
+	engine.HandleMethodNotAllowed = false
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/user/info", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("PUT /user/info code = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestEngineNoRoute(t *testing.T) {
+	engine := New()
+	var trace []string
+	engine.Use(func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			next(ctx)
+			trace = append(trace, fmt.Sprintf("%s %d", ctx.Request.Method, ctx.StatusCode))
+		}
+	})
+	engine.Get("/user/info", func(ctx *Context) {})
+	engine.NoRoute(func(ctx *Context) {
+		ctx.JSON(http.StatusNotFound, map[string]string{"msg": "not found"})
+	})
+	engine.NoMethod(func(ctx *Context) {
+		ctx.JSON(http.StatusMethodNotAllowed, map[string]string{"msg": "not allowed"})
+	})
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/user/none", nil))
+	if w.Code != http.StatusNotFound || w.Body.String() != `{"msg":"not found"}` {
+		t.Errorf("GET /user/none = %d %q", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/user/info", nil))
+	if w.Code != http.StatusMethodNotAllowed || w.Body.String() != `{"msg":"not allowed"}` {
+		t.Errorf("POST /user/info = %d %q", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Allow") == "" {
+		t.Errorf("POST /user/info missing Allow header")
+	}
+	if got := strings.Join(trace, ","); got != "GET 404,POST 405" {
+		t.Errorf("middleware trace = %q, want %q", got, "GET 404,POST 405")
+	}
+}
+
+func TestEngineOptionsMiddleware(t *testing.T) {
+	engine := New()
+	engine.Use(func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			ctx.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+			if ctx.Request.Header.Get("Origin") == "deny" {
+				ctx.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			next(ctx)
+		}
+	})
+	engine.Post("/user/info", func(ctx *Context) {})
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodOptions, "/user/info", nil))
+	if w.Code != http.StatusNoContent || w.Header().Get("Allow") != "OPTIONS, POST" {
+		t.Errorf("OPTIONS /user/info = %d %q", w.Code, w.Header().Get("Allow"))
+	}
+	if w.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Errorf("OPTIONS /user/info did not run global middleware")
+	}
+	r := httptest.NewRequest(http.MethodOptions, "/user/info", nil)
+	r.Header.Set("Origin", "deny")
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("OPTIONS /user/info with denied origin code = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+func TestEngineRedirect(t *testing.T) {
+	engine := New()
+	user := engine.Group("user")
+	user.Get("/info", func(ctx *Context) {})
+	user.Post("/list/", func(ctx *Context) {})
+	user.Get("/file/:name", func(ctx *Context) {
+		fmt.Fprint(ctx.Writer, ctx.Param("name"))
+	})
+
+	tests := []struct {
+		method   string
+		path     string
+		code     int
+		location string
+	}{
+		{http.MethodGet, "/user/info/", http.StatusMovedPermanently, "/user/info"},
+		{http.MethodGet, "/user/info/?id=1", http.StatusMovedPermanently, "/user/info?id=1"},
+		{http.MethodPost, "/user/list", http.StatusPermanentRedirect, "/user/list/"},
+		{http.MethodGet, "/user//info", http.StatusNotFound, ""},
+		{http.MethodGet, "/user/none/", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Code != tt.code || w.Header().Get("Location") != tt.location {
+			t.Errorf("%s %s = %d %q, want %d %q", tt.method, tt.path,
+				w.Code, w.Header().Get("Location"), tt.code, tt.location)
+		}
+	}
+
+	engine.RedirectFixedPath = true
+	for path, location := range map[string]string{
+		"/user//info":        "/user/info",
+		"/USER/Info":         "/user/info",
+		"/user/../user/info": "/user/info",
+		"/User/File/A.txt/":  "/user/file/A.txt",
+	} {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != location {
+			t.Errorf("GET %s = %d %q, want %d %q", path,
+				w.Code, w.Header().Get("Location"), http.StatusMovedPermanently, location)
+		}
+	}
+
+	engine.UseRawPath = true
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/user/file/a%2Fb.txt", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "a/b.txt" {
+		t.Errorf("GET /user/file/a%%2Fb.txt = %d %q, want %d %q", w.Code, w.Body.String(), http.StatusOK, "a/b.txt")
+	}
+}
+
+func TestEngineRedirectEscape(t *testing.T) {
+	engine := New()
+	engine.Group("").Get("/:name", func(ctx *Context) {})
+	engine.Get("/file/:name", func(ctx *Context) {})
+	for path, location := range map[string]string{
+		"/%5Cevil.com/":     "/%5Cevil.com",
+		"/%5C%5Cevil.com/":  "/%5C%5Cevil.com",
+		"/file/a%3Fb/":      "/file/a%3Fb",
+		"/file/a%23b/":      "/file/a%23b",
+		"/file/a%5Cb/":      "/file/a%5Cb",
+		"/file/a%3Fb/?id=1": "/file/a%3Fb?id=1",
+	} {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != location {
+			t.Errorf("GET %s = %d %q, want %d %q", path,
+				w.Code, w.Header().Get("Location"), http.StatusMovedPermanently, location)
+		}
+	}
+
+	engine.UseRawPath = true
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/file/a%2Fb%3F/", nil))
+	if got := w.Header().Get("Location"); got != "/file/a%2Fb%3F" {
+		t.Errorf("GET /file/a%%2Fb%%3F/ with UseRawPath Location = %q, want %q", got, "/file/a%2Fb%3F")
+	}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if got := engine.escapedPath(r, "//evil.com"); got != "/%2Fevil.com" {
+		t.Errorf("escapedPath(//evil.com) = %q, want %q", got, "/%2Fevil.com")
+	}
+}
+
+func routesTestHandler(ctx *Context) {}
+
+func TestEngineRoutes(t *testing.T) {
+	engine := New()
+	engine.Use(Logging)
+	engine.Get("/ping", routesTestHandler)
+	user := engine.Group("user")
+	user.Use(Recovery)
+	user.Post("/info", routesTestHandler)
+	user.Get("/info", routesTestHandler, Logging)
+
+	routes := engine.Routes()
+	want := []struct {
+		method      string
+		path        string
+		middlewares int
+	}{
+		{http.MethodGet, "/ping", 1},
+		{http.MethodGet, "/user/info", 3},
+		{http.MethodPost, "/user/info", 2},
+	}
+	if len(routes) != len(want) {
+		t.Fatalf("Routes() len = %d, want %d", len(routes), len(want))
+	}
+	for i, w := range want {
+		route := routes[i]
+		if route.Method != w.method || route.Path != w.path || len(route.Middlewares) != w.middlewares {
+			t.Errorf("Routes()[%d] = %s %s %v, want %s %s with %d middlewares",
+				i, route.Method, route.Path, route.Middlewares, w.method, w.path, w.middlewares)
+		}
+		if route.Handler != "github.com/ljinfu/cob.routesTestHandler" {
+			t.Errorf("Routes()[%d].Handler = %q", i, route.Handler)
+		}
+	}
+	if got := routes[1].Middlewares; got[0] != "github.com/ljinfu/cob.Logging" || got[1] != "github.com/ljinfu/cob.Recovery" {
+		t.Errorf("Routes()[1].Middlewares = %v", got)
+	}
+}
+
+func TestEngineURL(t *testing.T) {
+	engine := New()
+	user := engine.Group("user")
+	user.Get("/info/:id<int>", func(ctx *Context) {}).Name("user.info")
+	user.Get("/static/*filepath", func(ctx *Context) {}).Name("user.static")
+	user.Get("/redirect", func(ctx *Context) {
+		ctx.RedirectToRoute(http.StatusFound, "user.info", "id", 1, "from", "redirect")
+	})
+
+	tests := []struct {
+		name   string
+		params []interface{}
+		url    string
+		err    bool
+	}{
+		{"user.info", []interface{}{"id", 1}, "/user/info/1", false},
+		{"user.info", []interface{}{"id", 1, "page", 2, "q", "a b"}, "/user/info/1?page=2&q=a+b", false},
+		{"user.static", []interface{}{"filepath", "css/a b.css"}, "/user/static/css/a%20b.css", false},
+		{"user.info", []interface{}{"id", "abc"}, "", true},
+		{"user.info", []interface{}{"page", 1}, "", true},
+		{"user.info", []interface{}{"id"}, "", true},
+		{"user.none", nil, "", true},
+	}
+	for _, tt := range tests {
+		url, err := engine.URL(tt.name, tt.params...)
+		if (err != nil) != tt.err || url != tt.url {
+			t.Errorf("URL(%q, %v) = %q, %v, want %q", tt.name, tt.params, url, err, tt.url)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/user/redirect", nil))
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/user/info/1?from=redirect" {
+		t.Errorf("GET /user/redirect = %d %q", w.Code, w.Header().Get("Location"))
+	}
+
+	engine.SetFuncMap(nil)
+	tpl := template.Must(template.New("").Funcs(engine.funcMap).Parse(`{{url "user.info" "id" 2}}`))
+	var sb strings.Builder
+	if err := tpl.Execute(&sb, nil); err != nil || sb.String() != "/user/info/2" {
+		t.Errorf("template url = %q, %v", sb.String(), err)
+	}
+}
+
+func TestEngineHost(t *testing.T) {
+	engine := New()
+	write := func(body string) HandleFunc {
+		return func(ctx *Context) {
+			fmt.Fprint(ctx.Writer, body, ctx.Param("tenant"), ctx.Param("id"))
+		}
+	}
+	engine.Get("/user/:id", write("default"))
+	engine.Host("api.example.com").Get("/user/:id", write("api"))
+	tenant := engine.Host(":tenant.example.com").Group("user")
+	tenant.Get("/:id", write("tenant"))
+	tenant.Group("").Header("Accept-Version", "v2").Get("/:id", write("v2"))
+
+	tests := []struct {
+		host    string
+		version string
+		body    string
+	}{
+		{"api.example.com", "", "api1"},
+		{"API.example.com:8080", "", "api1"},
+		{"acme.example.com", "", "tenantacme1"},
+		{"acme.example.com", "v2", "v2acme1"},
+		{"example.com", "", "default1"},
+		{"a.b.example.com", "", "default1"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/user/1", nil)
+		r.Host = tt.host
+		if tt.version != "" {
+			r.Header.Set("Accept-Version", tt.version)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		if w.Body.String() != tt.body {
+			t.Errorf("GET %s/user/1 (version %q) body = %q, want %q", tt.host, tt.version, w.Body.String(), tt.body)
+		}
+	}
+}
@@ -113,7 +113,7 @@ func start() {
 		if err != nil {
 			fmt.Println(err)
 		}
-	})
+	}).Name("user.template")
 
 	user.Get("/json", func(ctx *cob.Context) {
 		u := struct {
@@ -137,8 +137,8 @@ func start() {
 	})
 
 	user.Get("/redirect", func(ctx *cob.Context) {
-		ctx.Redirect(http.StatusFound, "/user/template")
-		//ctx.Redirect(http.StatusOK, "/user/template")
+		ctx.RedirectToRoute(http.StatusFound, "user.template")
+		//ctx.Redirect(http.StatusFound, "/user/template")
 	})
 
 	user.Get("/query", func(ctx *cob.Context) {
@@ -245,6 +245,10 @@ func start() {
 		ctx.JSON(http.StatusOK, tokenStr)
 	})
 
+	engine.OnShutdown(p.Release, func() {
+		logger.Close()
+	})
+	engine.ShutdownOnSignal()
 	engine.Run(":8080")
 }
 
@@ -4,4 +4,7 @@ go 1.16
 
 require github.com/go-playground/validator/v10 v10.14.1
 
-require github.com/golang-jwt/jwt/v4 v4.5.0
+require (
+	github.com/golang-jwt/jwt/v4 v4.5.0
+	golang.org/x/net v0.8.0
+)
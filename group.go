@@ -1,51 +1,166 @@
 package cob
 
-import "net/http"
+import (
+	"net/http"
+	"path"
+	"strings"
+)
 
 const ANY = "ANY"
 
 //路由组
 type RouterGroup struct {
 	name              string
+	prefix            string //完整前缀，包含父组，根路由组为空
+	parent            *RouterGroup
+	children          []*RouterGroup
+	engine            *Engine
+	host              *hostMatcher //域名条件，nil 表示不限制
+	headers           []headerCondition
 	handleFuncMap     map[string]map[string]HandleFunc //第一层为router url  ,第二层为post/get等method
 	handlerMethodMap  map[string][]string
 	middlewareFuncMap map[string]map[string][]MiddlewareFunc
+	handlerChainMap   map[string]map[string]handlerChain //注册时编译好的中间件+handler调用链
+	maxMemoryMap      map[string]map[string]int64        //路由设置的 MaxMultipartMemory
 
 	treeNode    *treeNode
 	middlewares []MiddlewareFunc
 }
 
+//路由匹配结果，查找过程只读路由树，结果只属于当前请求
+type routeMatch struct {
+	routerName string
+	method     string
+	handlers   handlerChain //编译好的调用链，为nil表示路径匹配但method不匹配
+	params     Params
+}
+
+//子路由组，继承父组的中间件
+func (g *RouterGroup) Group(name string) *RouterGroup {
+	group := newRouterGroup(g.engine, g, name)
+	g.children = append(g.children, group)
+	g.engine.addGroup(group)
+	return group
+}
+
+//trimPrefix 前缀必须匹配完整的路径段，返回组内路由
+func (g *RouterGroup) trimPrefix(path string) (string, bool) {
+	if !strings.HasPrefix(path, g.prefix) {
+		return "", false
+	}
+	rest := path[len(g.prefix):]
+	if rest != "" && rest[0] != '/' {
+		return "", false
+	}
+	return rest, true
+}
+
+//fixedPath 忽略大小写查找路由，返回包含前缀的规范路径
+func (g *RouterGroup) fixedPath(path string) (string, bool) {
+	if len(path) < len(g.prefix) || !strings.EqualFold(path[:len(g.prefix)], g.prefix) {
+		return "", false
+	}
+	rest := path[len(g.prefix):]
+	if rest != "" && rest[0] != '/' {
+		return "", false
+	}
+	buf, ok := g.treeNode.matchFold(rest, make([]byte, 0, len(path)))
+	if !ok {
+		return "", false
+	}
+	return g.prefix + string(buf), true
+}
+
+//lookup 查找路由，第二个返回值表示路径是否匹配，params 为复用的参数缓冲
+func (g *RouterGroup) lookup(path, method string, params Params) (routeMatch, bool) {
+	node, params := g.treeNode.find(path, params)
+	if node == nil || !node.isEnd {
+		return routeMatch{}, false
+	}
+	match := routeMatch{routerName: node.routerName, params: params}
+	handlers := g.handlerChainMap[node.routerName]
+	if chain, ok := handlers[ANY]; ok {
+		match.method = ANY
+		match.handlers = chain
+		return match, true
+	}
+	//method 匹配
+	if chain, ok := handlers[method]; ok {
+		match.method = method
+		match.handlers = chain
+	}
+	return match, true
+}
+
 func (g *RouterGroup) Use(middlewareFunc ...MiddlewareFunc) {
 	g.middlewares = append(g.middlewares, middlewareFunc...)
+	g.recompile()
 }
 
-func (g *RouterGroup) MethodHandle(routerName, method string, ctx *Context, handleFunc HandleFunc) {
-	//组中间件
-	if g.middlewares != nil {
-		for _, middle := range g.middlewares {
-			handleFunc = middle(handleFunc)
+//中间件变化，已注册的路由需要重新编译，子组同样受影响
+func (g *RouterGroup) recompile() {
+	for routerName, handlers := range g.handleFuncMap {
+		for method := range handlers {
+			g.compile(routerName, method)
 		}
 	}
+	for _, child := range g.children {
+		child.recompile()
+	}
+}
 
-	//路由中间件
-	funcs := g.middlewareFuncMap[routerName][method]
-	if funcs != nil {
-		for _, f := range funcs {
-			handleFunc = f(handleFunc)
-		}
+func (g *RouterGroup) MethodHandle(routerName, method string, ctx *Context, handleFunc HandleFunc) {
+	ctx.handle(g.buildChain(routerName, method, handleFunc))
+}
+
+//编译路由调用链，请求时只需查找并调用一次
+func (g *RouterGroup) compile(routerName, method string) {
+	handler := g.handleFuncMap[routerName][method]
+	g.handlerChainMap[routerName][method] = g.buildChain(routerName, method, handler)
+}
+
+//buildChain 按 全局中间件、组中间件(父组在前)、路由中间件、handler 的顺序执行
+func (g *RouterGroup) buildChain(routerName, method string, handleFunc HandleFunc) handlerChain {
+	groupMiddlewares := g.combineMiddlewares()
+	routeMiddlewares := g.middlewareFuncMap[routerName][method]
+	chain := make(handlerChain, 0, len(g.engine.Middles)+len(groupMiddlewares)+len(routeMiddlewares)+2)
+
+	//路由设置的表单内存限制，在所有中间件之前生效
+	if n := g.maxMemoryMap[routerName][method]; n > 0 {
+		chain = append(chain, func(ctx *Context) {
+			ctx.maxMemory = n
+		})
+	}
+	for _, middle := range g.engine.Middles {
+		chain = append(chain, middle.adapt())
+	}
+	for _, middle := range groupMiddlewares {
+		chain = append(chain, middle.adapt())
 	}
-	handleFunc(ctx)
+	for _, middle := range routeMiddlewares {
+		chain = append(chain, middle.adapt())
+	}
+	return append(chain, handleFunc)
+}
+
+func (g *RouterGroup) combineMiddlewares() []MiddlewareFunc {
+	if g.parent == nil {
+		return g.middlewares
+	}
+	middlewares := append([]MiddlewareFunc{}, g.parent.combineMiddlewares()...)
+	return append(middlewares, g.middlewares...)
 }
 
 //func (g *RouterGroup) Add(pattern string, handler HandleFunc) {
 //	g.handleFuncMap[pattern] = handler
 //}
 
-func (g *RouterGroup) handle(pattern, method string, handler HandleFunc, middlewareFunc ...MiddlewareFunc) {
+func (g *RouterGroup) handle(pattern, method string, handler HandleFunc, middlewareFunc ...MiddlewareFunc) *Route {
 	_, ok := g.handleFuncMap[pattern]
 	if !ok {
 		g.handleFuncMap[pattern] = make(map[string]HandleFunc)
 		g.middlewareFuncMap[pattern] = make(map[string][]MiddlewareFunc)
+		g.handlerChainMap[pattern] = make(map[string]handlerChain)
 	}
 	_, ok = g.handleFuncMap[pattern][method]
 	if ok {
@@ -54,38 +169,54 @@ func (g *RouterGroup) handle(pattern, method string, handler HandleFunc, middlew
 	g.handleFuncMap[pattern][method] = handler
 
 	g.middlewareFuncMap[pattern][method] = append(g.middlewareFuncMap[pattern][method], middlewareFunc...)
+	g.compile(pattern, method)
 
 	g.treeNode.Put(pattern)
+	return &Route{group: g, method: method, pattern: pattern}
 }
 
-func (g *RouterGroup) Any(pattern string, handler HandleFunc, middlewareFunc ...MiddlewareFunc) {
-	g.handle(pattern, ANY, handler, middlewareFunc...)
+func (g *RouterGroup) Any(pattern string, handler HandleFunc, middlewareFunc ...MiddlewareFunc) *Route {
+	return g.handle(pattern, ANY, handler, middlewareFunc...)
 }
 
-func (g *RouterGroup) Get(pattern string, handler HandleFunc, middlewareFunc ...MiddlewareFunc) {
-	g.handle(pattern, http.MethodGet, handler, middlewareFunc...)
+func (g *RouterGroup) Get(pattern string, handler HandleFunc, middlewareFunc ...MiddlewareFunc) *Route {
+	return g.handle(pattern, http.MethodGet, handler, middlewareFunc...)
 }
 
-func (g *RouterGroup) Post(pattern string, handler HandleFunc, middlewareFunc ...MiddlewareFunc) {
-	g.handle(pattern, http.MethodPost, handler, middlewareFunc...)
+func (g *RouterGroup) Post(pattern string, handler HandleFunc, middlewareFunc ...MiddlewareFunc) *Route {
+	return g.handle(pattern, http.MethodPost, handler, middlewareFunc...)
 }
 
-func (g *RouterGroup) Put(pattern string, handler HandleFunc, middlewareFunc ...MiddlewareFunc) {
-	g.handle(pattern, http.MethodPut, handler, middlewareFunc...)
+func (g *RouterGroup) Put(pattern string, handler HandleFunc, middlewareFunc ...MiddlewareFunc) *Route {
+	return g.handle(pattern, http.MethodPut, handler, middlewareFunc...)
 }
 
-func (g *RouterGroup) Delete(pattern string, handler HandleFunc, middlewareFunc ...MiddlewareFunc) {
-	g.handle(pattern, http.MethodDelete, handler, middlewareFunc...)
+func (g *RouterGroup) Delete(pattern string, handler HandleFunc, middlewareFunc ...MiddlewareFunc) *Route {
+	return g.handle(pattern, http.MethodDelete, handler, middlewareFunc...)
 }
 
-func (g *RouterGroup) Patch(pattern string, handler HandleFunc, middlewareFunc ...MiddlewareFunc) {
-	g.handle(pattern, http.MethodPatch, handler, middlewareFunc...)
+func (g *RouterGroup) Patch(pattern string, handler HandleFunc, middlewareFunc ...MiddlewareFunc) *Route {
+	return g.handle(pattern, http.MethodPatch, handler, middlewareFunc...)
 }
 
-func (g *RouterGroup) Options(pattern string, handler HandleFunc, middlewareFunc ...MiddlewareFunc) {
-	g.handle(pattern, http.MethodOptions, handler, middlewareFunc...)
+func (g *RouterGroup) Options(pattern string, handler HandleFunc, middlewareFunc ...MiddlewareFunc) *Route {
+	return g.handle(pattern, http.MethodOptions, handler, middlewareFunc...)
 }
 
-func (g *RouterGroup) Head(pattern string, handler HandleFunc, middlewareFunc ...MiddlewareFunc) {
-	g.handle(pattern, http.MethodHead, handler, middlewareFunc...)
+func (g *RouterGroup) Head(pattern string, handler HandleFunc, middlewareFunc ...MiddlewareFunc) *Route {
+	return g.handle(pattern, http.MethodHead, handler, middlewareFunc...)
+}
+
+//静态文件服务 /static/*filepath
+func (g *RouterGroup) Static(relativePath, root string) {
+	g.StaticFS(relativePath, http.Dir(root))
+}
+
+func (g *RouterGroup) StaticFS(relativePath string, fs http.FileSystem) {
+	pattern := path.Join(relativePath, "/*filepath")
+	handler := func(ctx *Context) {
+		ctx.FileFormFS(ctx.Param("filepath"), fs)
+	}
+	g.Get(pattern, handler)
+	g.Head(pattern, handler)
 }
@@ -0,0 +1,131 @@
+package cob
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+//域名匹配 api.example.com *.example.com :tenant.example.com
+//* 匹配任意一级域名，:name 匹配任意一级域名并作为路由参数
+type hostMatcher struct {
+	pattern string
+	labels  []string
+}
+
+func newHostMatcher(pattern string) *hostMatcher {
+	labels := strings.Split(strings.ToLower(pattern), ".")
+	for _, label := range labels {
+		if label == "" || label == ":" || (strings.ContainsAny(label, ":*") && label != "*" && label[0] != ':') {
+			panic(fmt.Sprintf("invalid host pattern '%s'", pattern))
+		}
+	}
+	return &hostMatcher{pattern: pattern, labels: labels}
+}
+
+func (h *hostMatcher) match(host string) bool {
+	host = stripPort(host)
+	if strings.Count(host, ".")+1 != len(h.labels) {
+		return false
+	}
+	for _, label := range h.labels {
+		var part string
+		if i := strings.IndexByte(host, '.'); i >= 0 {
+			part, host = host[:i], host[i+1:]
+		} else {
+			part, host = host, ""
+		}
+		if part == "" {
+			return false
+		}
+		if label != "*" && label[0] != ':' && !strings.EqualFold(label, part) {
+			return false
+		}
+	}
+	return true
+}
+
+//params 将 :name 匹配的域名段追加到路由参数
+func (h *hostMatcher) params(host string, params Params) Params {
+	parts := strings.Split(stripPort(host), ".")
+	for i, label := range h.labels {
+		if label[0] == ':' && i < len(parts) {
+			params = append(params, Param{Key: label[1:], Value: parts[i]})
+		}
+	}
+	return params
+}
+
+func stripPort(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
+
+type headerCondition struct {
+	key   string
+	value string
+}
+
+//Host 限制路由组只匹配指定域名，子组同样受限
+func (g *RouterGroup) Host(pattern string) *RouterGroup {
+	g.host = newHostMatcher(pattern)
+	g.engine.sortGroups()
+	return g
+}
+
+//Header 限制路由组只匹配请求头为指定值的请求，如 Accept-Version: v2，子组同样受限
+func (g *RouterGroup) Header(key, value string) *RouterGroup {
+	g.headers = append(g.headers, headerCondition{key: key, value: value})
+	g.engine.sortGroups()
+	return g
+}
+
+//Host 创建只匹配指定域名的路由组
+func (r *Router) Host(pattern string) *RouterGroup {
+	return r.RouterGroup.Group("").Host(pattern)
+}
+
+//conditions 路由组及父组的条件数量，域名条件只计算非通配的域名段
+func (g *RouterGroup) conditions() (hosts, staticLabels, headers int) {
+	for group := g; group != nil; group = group.parent {
+		if group.host != nil {
+			hosts++
+			for _, label := range group.host.labels {
+				if label != "*" && label[0] != ':' {
+					staticLabels++
+				}
+			}
+		}
+		headers += len(group.headers)
+	}
+	return
+}
+
+//matchRequest 检查请求是否满足路由组及父组的域名、请求头条件
+func (g *RouterGroup) matchRequest(r *http.Request) bool {
+	for group := g; group != nil; group = group.parent {
+		if group.host != nil && !group.host.match(r.Host) {
+			return false
+		}
+		for _, h := range group.headers {
+			if r.Header.Get(h.key) != h.value {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+//hostParams 追加域名中的路由参数，父组在前
+func (g *RouterGroup) hostParams(r *http.Request, params Params) Params {
+	if g.parent != nil {
+		params = g.parent.hostParams(r, params)
+	}
+	if g.host != nil {
+		params = g.host.params(r.Host, params)
+	}
+	return params
+}
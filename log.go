@@ -56,6 +56,7 @@ type LogFormatterParams struct {
 	Request        *http.Request
 	TimeStamp      time.Time
 	StatusCode     int
+	BodySize       int //响应体大小
 	Latency        time.Duration
 	ClientIP       net.IP
 	Method         string
@@ -98,7 +99,7 @@ func LoggingWithConfig(config LoggerConfig, next HandleFunc) HandleFunc {
 		ip, _, _ := net.SplitHostPort(strings.TrimSpace(r.RemoteAddr))
 		clientIp := net.ParseIP(ip)
 		method := r.Method
-		code := ctx.StatusCode
+		code := ctx.Writer.Status()
 
 		if raw != "" {
 			path = path + "?" + raw
@@ -109,6 +110,7 @@ func LoggingWithConfig(config LoggerConfig, next HandleFunc) HandleFunc {
 		params.Path = path
 		params.ClientIP = clientIp
 		params.StatusCode = code
+		params.BodySize = ctx.Writer.Size()
 		params.Latency = latency
 		fmt.Fprintf(out, formatter(params))
 	}
@@ -179,6 +179,22 @@ func (l *Logger) SetLogPath(logPath string) {
 	})
 }
 
+//Close 关闭日志文件，标准输出不会被关闭
+func (l *Logger) Close() error {
+	var err error
+	for _, out := range l.Outs {
+		if out.Out == os.Stdout || out.Out == os.Stderr {
+			continue
+		}
+		if closer, ok := out.Out.(io.Closer); ok {
+			if closeErr := closer.Close(); closeErr != nil && err == nil {
+				err = closeErr
+			}
+		}
+	}
+	return err
+}
+
 func FileWriter(name string) io.Writer {
 	w, err := os.OpenFile(name, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
 	if err != nil {
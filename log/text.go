@@ -21,5 +21,5 @@ func (t *TextFormatter) Format(param *LoggingFormatParam) string {
 		fieldsStr = sb.String()
 	}
 	return fmt.Sprintf("[cob] %v | level=%s | msg=%v %s \n",
-		now.Format("2006-01-02 15:04:05"), param.Level, param.Msg, fieldsStr)
+		now.Format("2006-01-02 15:04:05"), param.Level.Level(), param.Msg, fieldsStr)
 }
@@ -0,0 +1,46 @@
+package cob
+
+import (
+	"fmt"
+	"os"
+)
+
+const EnvCobMode = "COB_MODE"
+
+const (
+	DebugMode   = "debug"
+	ReleaseMode = "release"
+	TestMode    = "test"
+)
+
+var cobMode = DebugMode
+
+func init() {
+	SetMode(os.Getenv(EnvCobMode))
+}
+
+//SetMode 设置运行模式，为空时使用debug模式
+func SetMode(value string) {
+	switch value {
+	case "", DebugMode:
+		cobMode = DebugMode
+	case ReleaseMode, TestMode:
+		cobMode = value
+	default:
+		panic("cob mode unknown: " + value)
+	}
+}
+
+func Mode() string {
+	return cobMode
+}
+
+func IsDebugging() bool {
+	return cobMode == DebugMode
+}
+
+func debugPrint(format string, values ...interface{}) {
+	if IsDebugging() {
+		fmt.Fprintf(DefaultWriter, "[cob-debug] "+format, values...)
+	}
+}
@@ -0,0 +1,126 @@
+package cob
+
+import (
+	"errors"
+	"github.com/ljinfu/cob/render"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+//ErrNotAcceptable 请求的 Accept 中没有可以提供的格式
+var ErrNotAcceptable = errors.New("the accepted formats are not offered by the server")
+
+//Negotiate 根据请求头 Accept 从 offers 中选择响应格式，Accept 为空时使用第一个，
+//offers 可以是 render.Json render.Xml render.HTML render.String 或自定义的 Render，
+//格式由 Render 的 WriteContentType 决定。没有可接受的格式时返回406和 ErrNotAcceptable
+//ctx.Negotiate(http.StatusOK, &render.Json{Data: user}, &render.Xml{Data: user})
+func (c *Context) Negotiate(status int, offers ...render.Render) error {
+	offered := make([]string, len(offers))
+	for i, offer := range offers {
+		probe := &discardResponseWriter{header: make(http.Header)}
+		offer.WriteContentType(probe)
+		offered[i] = mediaType(probe.header.Get("Content-Type"))
+	}
+	c.Writer.Header().Add("Vary", "Accept")
+	if format := c.NegotiateFormat(offered...); format != "" {
+		for i := range offered {
+			if offered[i] == format {
+				return c.Render(status, offers[i])
+			}
+		}
+	}
+	c.String(http.StatusNotAcceptable, http.StatusText(http.StatusNotAcceptable))
+	return ErrNotAcceptable
+}
+
+//NegotiateFormat 返回 offered 中请求最希望接收的格式，q 相同时按 offered 的顺序，
+//没有可接受的格式时返回空字符串
+//switch ctx.NegotiateFormat("application/json", "text/csv") {...}
+func (c *Context) NegotiateFormat(offered ...string) string {
+	if len(offered) == 0 {
+		return ""
+	}
+	ranges := parseAccept(c.Request.Header.Get("Accept"))
+	if len(ranges) == 0 {
+		return offered[0]
+	}
+	best, bestQ := "", 0.0
+	for _, offer := range offered {
+		typ, sub := splitMediaType(mediaType(offer))
+		//使用最具体的匹配项的 q 值
+		specificity, q := -1, 0.0
+		for _, r := range ranges {
+			if s, ok := r.match(typ, sub); ok && s > specificity {
+				specificity, q = s, r.q
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+//acceptRange Accept 中的一项，如 text/html;q=0.8
+type acceptRange struct {
+	typ string
+	sub string
+	q   float64
+}
+
+//match 返回匹配的具体程度，*/* 为0，type/* 为1，type/subtype 为2
+func (r acceptRange) match(typ, sub string) (int, bool) {
+	switch {
+	case r.typ == "*" && r.sub == "*":
+		return 0, true
+	case r.typ == typ && r.sub == "*":
+		return 1, true
+	case r.typ == typ && r.sub == sub:
+		return 2, true
+	}
+	return 0, false
+}
+
+func parseAccept(header string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		typ, sub := splitMediaType(strings.ToLower(strings.TrimSpace(fields[0])))
+		if typ == "" {
+			continue
+		}
+		r := acceptRange{typ: typ, sub: sub, q: 1}
+		for _, param := range fields[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) == 2 && strings.TrimSpace(kv[0]) == "q" {
+				q, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+				if err != nil || q < 0 || q > 1 {
+					q = 0
+				}
+				r.q = q
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges
+}
+
+//mediaType 去掉 Content-Type 中的参数，如 application/json;charset=utf-8
+func mediaType(contentType string) string {
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
+
+func splitMediaType(mt string) (string, string) {
+	if mt == "*" {
+		return "*", "*"
+	}
+	i := strings.IndexByte(mt, '/')
+	if i <= 0 || i == len(mt)-1 {
+		return "", ""
+	}
+	return mt[:i], mt[i+1:]
+}
@@ -0,0 +1,73 @@
+package cob
+
+import (
+	"github.com/ljinfu/cob/render"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type csvRender struct {
+	rows string
+}
+
+func (r *csvRender) Render(w http.ResponseWriter, code int) error {
+	r.WriteContentType(w)
+	w.WriteHeader(code)
+	_, err := w.Write([]byte(r.rows))
+	return err
+}
+
+func (r *csvRender) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/csv")
+}
+
+func TestContextNegotiate(t *testing.T) {
+	type user struct {
+		Name string `json:"name" xml:"name"`
+	}
+	engine := New()
+	engine.Get("/user", func(ctx *Context) {
+		u := user{Name: "cob"}
+		ctx.Negotiate(http.StatusOK,
+			&render.Json{Data: u},
+			&render.Xml{Data: u},
+			&render.String{Format: "name=%s", Data: []interface{}{u.Name}},
+			&csvRender{rows: "name\ncob\n"},
+		)
+	})
+	for _, tt := range []struct {
+		accept      string
+		code        int
+		contentType string
+	}{
+		{"", http.StatusOK, "application/json;charset=utf-8"},
+		{"*/*", http.StatusOK, "application/json;charset=utf-8"},
+		{"application/xml", http.StatusOK, "application/xml;charset=utf-8"},
+		{"text/html, application/xml;q=0.9, */*;q=0.8", http.StatusOK, "application/xml;charset=utf-8"},
+		{"application/json;q=0.5, text/*", http.StatusOK, "text/plain;charset=utf-8"},
+		{"text/*;q=0.5, text/csv", http.StatusOK, "text/csv"},
+		{"application/json;q=0, */*;q=0.1", http.StatusOK, "application/xml;charset=utf-8"},
+		{"image/png", http.StatusNotAcceptable, "text/plain;charset=utf-8"},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/user", nil)
+		r.Header.Set("Accept", tt.accept)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		if w.Code != tt.code || w.Header().Get("Content-Type") != tt.contentType {
+			t.Errorf("Accept %q = %d %q, want %d %q", tt.accept, w.Code, w.Header().Get("Content-Type"), tt.code, tt.contentType)
+		}
+	}
+}
+
+func TestContextNegotiateFormat(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept", "text/html;level=1, application/json;q=0.8")
+	ctx := &Context{Request: r}
+	if got := ctx.NegotiateFormat("application/json", "text/html"); got != "text/html" {
+		t.Errorf("NegotiateFormat() = %q, want %q", got, "text/html")
+	}
+	if got := ctx.NegotiateFormat("image/png"); got != "" {
+		t.Errorf("NegotiateFormat(image/png) = %q, want empty", got)
+	}
+}
@@ -13,10 +13,11 @@ func Recovery(next HandleFunc) HandleFunc {
 	return func(ctx *Context) {
 		defer func() {
 			if err := recover(); err != nil {
+				ctx.Abort()
 				err2:=err.(error)
 				if err2 != nil {
 					var le *lbe.LError
-					if errors.As(err2, le) {
+					if errors.As(err2, &le) {
 						le.ExecResult()
 						return
 					}
@@ -12,9 +12,9 @@ type Redirect struct {
 	Location string
 }
 
-func (r *Redirect) Render(w http.ResponseWriter,code int) error {
+func (r *Redirect) Render(w http.ResponseWriter, code int) error {
 	r.WriteContentType(w)
-	w.WriteHeader(code)
+	//http.Redirect 会设置 Location 并写入状态码
 	if (r.Code < http.StatusMultipleChoices || r.Code > http.StatusPermanentRedirect) && r.Code != http.StatusCreated {
 		return errors.New(fmt.Sprintf("cannot redirect with status code %d", r.Code))
 	}
@@ -0,0 +1,138 @@
+package cob
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+)
+
+const noWritten = -1
+
+//ResponseWriter 记录响应状态码、响应体大小以及响应头是否已经发送
+type ResponseWriter interface {
+	http.ResponseWriter
+	http.Flusher
+	http.Hijacker
+	http.Pusher
+
+	//响应状态码，没有调用 WriteHeader 时为200
+	Status() int
+	//已写入的响应体字节数，响应头未发送时为-1
+	Size() int
+	//响应头是否已经发送
+	Written() bool
+	//立即发送响应头
+	WriteHeaderNow()
+	//被包装的 http.ResponseWriter
+	Unwrap() http.ResponseWriter
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (w *responseWriter) reset(writer http.ResponseWriter) {
+	w.ResponseWriter = writer
+	w.status = http.StatusOK
+	w.size = noWritten
+}
+
+//WriteHeader 响应头只能发送一次，重复调用会被忽略
+func (w *responseWriter) WriteHeader(code int) {
+	if code <= 0 {
+		return
+	}
+	if w.Written() {
+		if code != w.status {
+			debugPrint("[WARNING] Headers were already written. Wanted to override status code %d with %d\n", w.status, code)
+		}
+		return
+	}
+	w.status = code
+	w.size = 0
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) WriteHeaderNow() {
+	if !w.Written() {
+		w.WriteHeader(w.status)
+	}
+}
+
+func (w *responseWriter) Write(data []byte) (int, error) {
+	w.WriteHeaderNow()
+	n, err := w.ResponseWriter.Write(data)
+	w.size += n
+	return n, err
+}
+
+func (w *responseWriter) Status() int {
+	return w.status
+}
+
+func (w *responseWriter) Size() int {
+	return w.size
+}
+
+func (w *responseWriter) Written() bool {
+	return w.size != noWritten
+}
+
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *responseWriter) Flush() {
+	w.WriteHeaderNow()
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+//Hijack 接管连接后不能再通过 ResponseWriter 写入
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("the ResponseWriter doesn't support the Hijacker interface")
+	}
+	if w.size < 0 {
+		w.size = 0
+	}
+	return hijacker.Hijack()
+}
+
+//Push HTTP/2 服务器推送，不支持时返回 http.ErrNotSupported
+func (w *responseWriter) Push(target string, opts *http.PushOptions) error {
+	if pusher, ok := w.ResponseWriter.(http.Pusher); ok {
+		return pusher.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+//HEAD 请求使用GET路由处理时丢弃响应体
+type headResponseWriter struct {
+	ResponseWriter
+}
+
+func (w *headResponseWriter) Write(data []byte) (int, error) {
+	w.WriteHeaderNow()
+	return len(data), nil
+}
+
+//丢弃所有写入，用于 Context.Copy 的副本以及获取 Render 的 Content-Type
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w *discardResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *discardResponseWriter) Write(data []byte) (int, error) {
+	return len(data), nil
+}
+
+func (w *discardResponseWriter) WriteHeader(int) {}
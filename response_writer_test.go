@@ -0,0 +1,73 @@
+package cob
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponseWriter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := &responseWriter{}
+	w.reset(rec)
+	if w.Written() || w.Status() != http.StatusOK || w.Size() != -1 {
+		t.Fatalf("new writer: written = %v, status = %d, size = %d", w.Written(), w.Status(), w.Size())
+	}
+	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(http.StatusInternalServerError)
+	fmt.Fprintf(w, "%s", "hello")
+	if rec.Code != http.StatusCreated || w.Status() != http.StatusCreated {
+		t.Errorf("status = %d, recorded = %d, want %d", w.Status(), rec.Code, http.StatusCreated)
+	}
+	if w.Size() != 5 {
+		t.Errorf("Size() = %d, want 5", w.Size())
+	}
+	w.Flush()
+	if !rec.Flushed {
+		t.Errorf("Flush not passed through")
+	}
+	if _, _, err := w.Hijack(); err == nil {
+		t.Errorf("Hijack on recorder succeeded")
+	}
+	if err := w.Push("/app.js", nil); err != http.ErrNotSupported {
+		t.Errorf("Push() = %v, want %v", err, http.ErrNotSupported)
+	}
+}
+
+func TestLoggingStatus(t *testing.T) {
+	engine := New()
+	var status, size int
+	var out bytes.Buffer
+	engine.Use(func(next HandleFunc) HandleFunc {
+		return LoggingWithConfig(LoggerConfig{
+			Formatter: func(params *LogFormatterParams) string {
+				status, size = params.StatusCode, params.BodySize
+				return ""
+			},
+			out: &out,
+		}, next)
+	})
+	engine.Get("/fprintf", func(ctx *Context) {
+		fmt.Fprintf(ctx.Writer, "%s get info", "cob")
+	})
+	engine.Get("/created", func(ctx *Context) {
+		ctx.Writer.WriteHeader(http.StatusCreated)
+	})
+	for _, tt := range []struct {
+		path   string
+		status int
+		size   int
+	}{
+		{"/fprintf", http.StatusOK, 12},
+		{"/created", http.StatusCreated, 0},
+		{"/missing", http.StatusNotFound, len("GET /missing not found \n ")},
+	} {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if status != tt.status || size != tt.size || w.Code != tt.status {
+			t.Errorf("GET %s logged status = %d size = %d, want %d %d", tt.path, status, size, tt.status, tt.size)
+		}
+	}
+}
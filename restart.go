@@ -0,0 +1,259 @@
+package cob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+//热重启时传给新进程的环境变量
+const (
+	envListenFds   = "COB_LISTEN_FDS"   //继承的 listener 数量，fd 从3开始
+	envListenAddrs = "COB_LISTEN_ADDRS" //每个 listener 对应的地址，换行分隔
+	envReadyFd     = "COB_READY_FD"     //新进程开始服务后写入该 fd 通知旧进程
+)
+
+//serveListener 正在服务的 listener，addr 为 Run 时传入的地址
+type serveListener struct {
+	addr string
+	l    net.Listener
+}
+
+var (
+	inheritOnce sync.Once
+	inheritLock sync.Mutex
+	inherited   map[string]net.Listener
+	inheritAddr []string //继承的 listener 地址，保持旧进程中的顺序
+	readyFile   *os.File
+)
+
+//loadInherited 读取热重启时旧进程传入的 listener
+func loadInherited() {
+	n, err := strconv.Atoi(os.Getenv(envListenFds))
+	if err != nil || n <= 0 {
+		return
+	}
+	addrs := strings.Split(os.Getenv(envListenAddrs), "\n")
+	if fd, err := strconv.Atoi(os.Getenv(envReadyFd)); err == nil {
+		readyFile = os.NewFile(uintptr(fd), "cob-ready")
+	}
+	os.Unsetenv(envListenFds)
+	os.Unsetenv(envListenAddrs)
+	os.Unsetenv(envReadyFd)
+
+	inherited = make(map[string]net.Listener, n)
+	for i := 0; i < n && i < len(addrs); i++ {
+		file := os.NewFile(uintptr(systemdFdStart+i), addrs[i])
+		l, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			debugPrint("Inherit listener %s failed: %v\n", addrs[i], err)
+			continue
+		}
+		inherited[addrs[i]] = l
+		inheritAddr = append(inheritAddr, addrs[i])
+	}
+}
+
+//takeInherited 取出所有未被使用的继承 listener，用于 RunSystemd 的新进程
+func takeInherited() []net.Listener {
+	inheritOnce.Do(loadInherited)
+	inheritLock.Lock()
+	defer inheritLock.Unlock()
+	var listeners []net.Listener
+	for _, addr := range inheritAddr {
+		if l, ok := inherited[addr]; ok {
+			listeners = append(listeners, l)
+			delete(inherited, addr)
+		}
+	}
+	return listeners
+}
+
+//listen 优先使用旧进程传入的 listener，unix socket 不是继承的时删除已存在的文件
+func listen(network, addr string) (net.Listener, error) {
+	inheritOnce.Do(loadInherited)
+	inheritLock.Lock()
+	l, ok := inherited[addr]
+	delete(inherited, addr)
+	inheritLock.Unlock()
+	if ok {
+		return l, nil
+	}
+	if network == "unix" {
+		if err := os.Remove(addr); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return net.Listen(network, addr)
+}
+
+//notifyReady 通知旧进程新进程已经开始服务
+func notifyReady() {
+	inheritOnce.Do(loadInherited)
+	inheritLock.Lock()
+	defer inheritLock.Unlock()
+	if readyFile == nil {
+		return
+	}
+	readyFile.Write([]byte{1})
+	readyFile.Close()
+	readyFile = nil
+}
+
+//Restart 热重启：启动新进程并传入所有 listener，新进程开始服务后平滑关闭当前服务，
+//期间不会断开连接。新进程使用相同的命令行参数，Run 时传入相同的地址即可继承 listener
+func (e *Engine) Restart() error {
+	e.serverLock.Lock()
+	if e.closed {
+		e.serverLock.Unlock()
+		return http.ErrServerClosed
+	}
+	listeners := append([]serveListener{}, e.listeners...)
+	e.serverLock.Unlock()
+	if len(listeners) == 0 {
+		return errors.New("no listener to hand off")
+	}
+
+	files := make([]*os.File, 0, len(listeners)+1)
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+	addrs := make([]string, 0, len(listeners))
+	for _, sl := range listeners {
+		filer, ok := sl.l.(interface {
+			File() (*os.File, error)
+		})
+		if !ok {
+			return fmt.Errorf("listener %s can not be handed off", sl.addr)
+		}
+		file, err := filer.File()
+		if err != nil {
+			return err
+		}
+		files = append(files, file)
+		addrs = append(addrs, sl.addr)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	files = append(files, w)
+	path, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(path, os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = files
+	cmd.Env = append(restartEnv(),
+		envListenFds+"="+strconv.Itoa(len(addrs)),
+		envListenAddrs+"="+strings.Join(addrs, "\n"),
+		envReadyFd+"="+strconv.Itoa(systemdFdStart+len(addrs)),
+	)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	w.Close()
+	files = files[:len(files)-1]
+	debugPrint("Restarting, new process %d\n", cmd.Process.Pid)
+
+	//新进程退出时管道关闭，Read 返回 EOF
+	ready := make(chan error, 1)
+	go func() {
+		_, err := r.Read(make([]byte, 1))
+		ready <- err
+	}()
+	var timeout <-chan time.Time
+	if e.RestartTimeout > 0 {
+		timer := time.NewTimer(e.RestartTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case err = <-ready:
+		if err != nil {
+			err = fmt.Errorf("new process exited before ready: %v", err)
+		}
+	case <-timeout:
+		err = errors.New("wait for new process ready timeout")
+	}
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+
+	//unix socket 文件已由新进程使用，关闭时不能删除
+	for _, sl := range listeners {
+		if ul, ok := sl.l.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+	}
+	ctx, cancel := e.shutdownContext()
+	defer cancel()
+	return e.Shutdown(ctx)
+}
+
+//restartEnv 去掉 systemd 和上次热重启的环境变量，新进程的 listener 只通过 COB_LISTEN_FDS 传入
+func restartEnv() []string {
+	env := make([]string, 0, len(os.Environ()))
+	for _, kv := range os.Environ() {
+		switch kv[:strings.IndexByte(kv+"=", '=')] {
+		case "LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES", envListenFds, envListenAddrs, envReadyFd:
+			continue
+		}
+		env = append(env, kv)
+	}
+	return env
+}
+
+//RestartOnSignal 收到信号后调用 Restart，默认监听 SIGHUP，重启失败时继续服务
+func (e *Engine) RestartOnSignal(sig ...os.Signal) {
+	if len(sig) == 0 {
+		sig = []os.Signal{syscall.SIGHUP}
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sig...)
+	go func() {
+		defer signal.Stop(ch)
+		for {
+			select {
+			case s := <-ch:
+				debugPrint("Received signal %v, restarting\n", s)
+				err := e.Restart()
+				if err == nil {
+					return
+				}
+				if e.Logger != nil {
+					e.Logger.Error(err)
+				}
+			case <-e.done:
+				return
+			}
+		}
+	}()
+}
+
+func (e *Engine) shutdownContext() (context.Context, context.CancelFunc) {
+	if e.ShutdownTimeout > 0 {
+		return context.WithTimeout(context.Background(), e.ShutdownTimeout)
+	}
+	return context.WithCancel(context.Background())
+}
@@ -1,23 +1,90 @@
 package cob
 
+import (
+	"sort"
+	"strings"
+)
+
 type HandleFunc func(ctx *Context)
 
 type MiddlewareFunc func(handleFunc HandleFunc) HandleFunc
 
+//调用链，中间件按注册顺序在前，handler 在最后
+type handlerChain []HandleFunc
+
+//adapt 将中间件转换为调用链中的一环，传入的 next 与 ctx.Next 等价，
+//两者都没有调用时后续处理不再执行
+func (m MiddlewareFunc) adapt() HandleFunc {
+	handler := m(nextHandler)
+	return func(ctx *Context) {
+		index := ctx.index
+		handler(ctx)
+		if ctx.index == index {
+			ctx.Abort()
+		} else if !ctx.IsAborted() {
+			//后续处理已经在 next 中执行，panic 被恢复时也不再继续
+			ctx.index = len(ctx.handlers)
+		}
+	}
+}
+
+func nextHandler(ctx *Context) {
+	ctx.Next()
+}
+
 type Router struct {
-	groups []*RouterGroup
-	engin  *Engine
+	*RouterGroup                //根路由组，注册没有前缀的路由
+	groups       []*RouterGroup //所有路由组，条件更具体的在前，其次按前缀从长到短排列
+	engin        *Engine
 }
 
 func (r *Router) Group(name string) *RouterGroup {
-	group := &RouterGroup{
+	return r.RouterGroup.Group(name)
+}
+
+func newRouterGroup(engine *Engine, parent *RouterGroup, name string) *RouterGroup {
+	name = strings.Trim(name, "/")
+	prefix := ""
+	if parent != nil {
+		prefix = parent.prefix
+	}
+	if name != "" {
+		prefix += "/" + name
+	}
+	return &RouterGroup{
 		name:              name,
+		prefix:            prefix,
+		parent:            parent,
+		engine:            engine,
 		handleFuncMap:     make(map[string]map[string]HandleFunc),
 		handlerMethodMap:  make(map[string][]string),
 		middlewareFuncMap: make(map[string]map[string][]MiddlewareFunc),
-		treeNode:          &treeNode{name: "/", children: make([]*treeNode, 0)},
+		handlerChainMap:   make(map[string]map[string]handlerChain),
+		maxMemoryMap:      make(map[string]map[string]int64),
+		treeNode:          &treeNode{},
 	}
-	group.Use(r.engin.Middles...)
+}
+
+//addGroup 请求优先匹配更具体的路由组
+func (r *Router) addGroup(group *RouterGroup) {
 	r.groups = append(r.groups, group)
-	return group
+	r.sortGroups()
+}
+
+func (r *Router) sortGroups() {
+	//域名条件 > 非通配域名段多 > 请求头条件多 > 前缀长
+	sort.SliceStable(r.groups, func(i, j int) bool {
+		hi, si, ci := r.groups[i].conditions()
+		hj, sj, cj := r.groups[j].conditions()
+		if (hi > 0) != (hj > 0) {
+			return hi > 0
+		}
+		if si != sj {
+			return si > sj
+		}
+		if ci != cj {
+			return ci > cj
+		}
+		return len(r.groups[i].prefix) > len(r.groups[j].prefix)
+	})
 }
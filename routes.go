@@ -0,0 +1,196 @@
+package cob
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+//注册的路由，可以设置路由名称用于反向生成url
+type Route struct {
+	group   *RouterGroup
+	method  string
+	pattern string
+	name    string
+}
+
+//Name 设置路由名称，名称全局唯一
+func (r *Route) Name(name string) *Route {
+	e := r.group.engine
+	if _, ok := e.namedRoutes[name]; ok {
+		panic(fmt.Sprintf("route name '%s' already exists", name))
+	}
+	if e.namedRoutes == nil {
+		e.namedRoutes = make(map[string]*Route)
+	}
+	r.name = name
+	e.namedRoutes[name] = r
+	return r
+}
+
+//MaxMultipartMemory 设置该路由解析 multipart 表单时最多使用的内存
+func (r *Route) MaxMultipartMemory(n int64) *Route {
+	g := r.group
+	if g.maxMemoryMap[r.pattern] == nil {
+		g.maxMemoryMap[r.pattern] = make(map[string]int64)
+	}
+	g.maxMemoryMap[r.pattern][r.method] = n
+	g.compile(r.pattern, r.method)
+	return r
+}
+
+//Path 包含路由组前缀的完整路由
+func (r *Route) Path() string {
+	return r.group.prefix + r.pattern
+}
+
+//URL 根据路由名称生成url，params 为 key,value 交替的参数，
+//路由中的参数填充到路径中，其余参数作为query
+//engine.URL("user.info", "id", 1, "page", 2) -> /user/info/1?page=2
+func (e *Engine) URL(name string, params ...interface{}) (string, error) {
+	route, ok := e.namedRoutes[name]
+	if !ok {
+		return "", fmt.Errorf("route '%s' not found", name)
+	}
+	if len(params)%2 != 0 {
+		return "", errors.New("url params must be key value pairs")
+	}
+	values := make(map[string]string, len(params)/2)
+	keys := make([]string, 0, len(params)/2)
+	for i := 0; i < len(params); i += 2 {
+		key, ok := params[i].(string)
+		if !ok {
+			return "", fmt.Errorf("url param key %v is not a string", params[i])
+		}
+		if _, exist := values[key]; !exist {
+			keys = append(keys, key)
+		}
+		values[key] = fmt.Sprint(params[i+1])
+	}
+
+	path := route.Path()
+	var sb strings.Builder
+	rest := path
+	for rest != "" {
+		i := wildcardIndex(rest)
+		if i < 0 {
+			sb.WriteString(rest)
+			break
+		}
+		sb.WriteString(rest[:i])
+		seg := rest[i : i+wildcardLen(rest[i:], path)]
+		rest = rest[i+len(seg):]
+		if seg == "*" {
+			return "", fmt.Errorf("route '%s' has an anonymous wildcard", name)
+		}
+		var key string
+		var check func(string) bool
+		if seg[0] == '*' {
+			key = seg[1:]
+		} else {
+			key, _, check = parseParam(seg, path)
+		}
+		val, ok := values[key]
+		if !ok {
+			return "", fmt.Errorf("missing param '%s' for route '%s'", key, name)
+		}
+		if check != nil && !check(val) {
+			return "", fmt.Errorf("param '%s' value '%s' does not match route '%s'", key, val, path)
+		}
+		delete(values, key)
+		if seg[0] == '*' {
+			//多段通配保留 /
+			parts := strings.Split(val, "/")
+			for j, part := range parts {
+				parts[j] = url.PathEscape(part)
+			}
+			sb.WriteString(strings.Join(parts, "/"))
+		} else {
+			sb.WriteString(url.PathEscape(val))
+		}
+	}
+
+	query := url.Values{}
+	for _, key := range keys {
+		if val, ok := values[key]; ok {
+			query.Set(key, val)
+		}
+	}
+	if len(query) > 0 {
+		sb.WriteString("?")
+		sb.WriteString(query.Encode())
+	}
+	return sb.String(), nil
+}
+
+//路由信息，用于生成文档和测试
+type RouteInfo struct {
+	Method      string
+	Path        string
+	Name        string
+	Handler     string
+	Middlewares []string //全局、组、路由中间件，按注册顺序
+	HandleFunc  HandleFunc
+}
+
+type RoutesInfo []RouteInfo
+
+//Routes 返回所有注册的路由，按路径和method排序
+func (e *Engine) Routes() RoutesInfo {
+	names := make(map[string]string)
+	for name, route := range e.namedRoutes {
+		names[route.Path()+" "+route.method] = name
+	}
+	var routes RoutesInfo
+	for _, group := range e.groups {
+		for routerName, handlers := range group.handleFuncMap {
+			for method, handler := range handlers {
+				middlewares := make([]string, 0)
+				for _, middle := range e.Middles {
+					middlewares = append(middlewares, nameOfFunction(middle))
+				}
+				for _, middle := range group.combineMiddlewares() {
+					middlewares = append(middlewares, nameOfFunction(middle))
+				}
+				for _, middle := range group.middlewareFuncMap[routerName][method] {
+					middlewares = append(middlewares, nameOfFunction(middle))
+				}
+				routes = append(routes, RouteInfo{
+					Method:      method,
+					Path:        group.prefix + routerName,
+					Name:        names[group.prefix+routerName+" "+method],
+					Handler:     nameOfFunction(handler),
+					Middlewares: middlewares,
+					HandleFunc:  handler,
+				})
+			}
+		}
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+func (e *Engine) debugPrintRoutes() {
+	if !IsDebugging() {
+		return
+	}
+	e.routesOnce.Do(func() {
+		for _, route := range e.Routes() {
+			debugPrint("%-6s %-25s --> %s (%d middlewares)\n",
+				route.Method, route.Path, route.Handler, len(route.Middlewares))
+		}
+	})
+}
+
+func nameOfFunction(f interface{}) string {
+	return runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
+}
@@ -0,0 +1,245 @@
+package cob
+
+import (
+	"context"
+	"errors"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+func (e *Engine) Run(addr string) error {
+	e.debugPrintRoutes()
+	debugPrint("Listening and serving HTTP on %s\n", addr)
+	if addr == "" {
+		addr = ":http"
+	}
+	l, err := listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return e.serve(e.newServer(addr), l, "", "")
+}
+
+func (e *Engine) RunTLS(addr, certFile, keyFile string) error {
+	e.debugPrintRoutes()
+	debugPrint("Listening and serving HTTPS on %s\n", addr)
+	if addr == "" {
+		addr = ":https"
+	}
+	l, err := listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return e.serve(e.newServer(addr), l, certFile, keyFile)
+}
+
+//RunListener 在已有的 listener 上提供服务
+func (e *Engine) RunListener(l net.Listener) error {
+	e.debugPrintRoutes()
+	debugPrint("Listening and serving HTTP on listener %s\n", l.Addr())
+	return e.serve(e.newServer(l.Addr().String()), l, "", "")
+}
+
+func (e *Engine) RunListenerTLS(l net.Listener, certFile, keyFile string) error {
+	e.debugPrintRoutes()
+	debugPrint("Listening and serving HTTPS on listener %s\n", l.Addr())
+	return e.serve(e.newServer(l.Addr().String()), l, certFile, keyFile)
+}
+
+//RunUnix 在 unix socket 上提供服务，已存在的 socket 文件会被删除
+func (e *Engine) RunUnix(file string) error {
+	e.debugPrintRoutes()
+	debugPrint("Listening and serving HTTP on unix:%s\n", file)
+	l, err := listen("unix", file)
+	if err != nil {
+		return err
+	}
+	return e.serve(e.newServer(file), l, "", "")
+}
+
+//RunWithTLS 同时提供 HTTP 和 HTTPS 服务
+func (e *Engine) RunWithTLS(addr, tlsAddr, certFile, keyFile string) error {
+	return e.runAll(func() error {
+		return e.Run(addr)
+	}, func() error {
+		return e.RunTLS(tlsAddr, certFile, keyFile)
+	})
+}
+
+//RunSystemd 在 systemd socket 激活传入的所有 listener 上提供服务
+func (e *Engine) RunSystemd() error {
+	listeners, err := SystemdListeners()
+	if err != nil {
+		return err
+	}
+	if len(listeners) == 0 {
+		return errors.New("no listeners passed by systemd")
+	}
+	runs := make([]func() error, 0, len(listeners))
+	for _, l := range listeners {
+		l := l
+		runs = append(runs, func() error {
+			return e.RunListener(l)
+		})
+	}
+	return e.runAll(runs...)
+}
+
+//SystemdListeners 读取 systemd socket 激活的 listener，LISTEN_FDS 从3开始。
+//热重启后的新进程没有 LISTEN_PID，返回旧进程传入的 listener
+func SystemdListeners() ([]net.Listener, error) {
+	if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+		return takeInherited(), nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil, err
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	listeners := make([]net.Listener, 0, n)
+	for i := 0; i < n; i++ {
+		name := "LISTEN_FD_" + strconv.Itoa(systemdFdStart+i)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		file := os.NewFile(uintptr(systemdFdStart+i), name)
+		l, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			for _, opened := range listeners {
+				opened.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
+
+const systemdFdStart = 3
+
+//runAll 同时运行多个服务，任意一个出错时关闭所有服务并返回该错误
+func (e *Engine) runAll(runs ...func() error) error {
+	errs := make(chan error, len(runs))
+	for _, run := range runs {
+		go func(run func() error) {
+			errs <- run()
+		}(run)
+	}
+	var err error
+	for range runs {
+		if runErr := <-errs; runErr != nil && err == nil {
+			err = runErr
+			go e.Shutdown(context.Background())
+		}
+	}
+	return err
+}
+
+func (e *Engine) newServer(addr string) *http.Server {
+	var handler http.Handler = e
+	if e.UseH2C {
+		handler = h2c.NewHandler(e, &http2.Server{IdleTimeout: e.IdleTimeout})
+	}
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadTimeout:       e.ReadTimeout,
+		ReadHeaderTimeout: e.ReadHeaderTimeout,
+		WriteTimeout:      e.WriteTimeout,
+		IdleTimeout:       e.IdleTimeout,
+	}
+}
+
+//serve 监听成功后执行 OnStart，Shutdown 后等待请求处理完成再返回，
+//所有服务共用 Shutdown 关闭
+func (e *Engine) serve(srv *http.Server, l net.Listener, certFile, keyFile string) error {
+	e.serverLock.Lock()
+	if e.closed {
+		e.serverLock.Unlock()
+		l.Close()
+		return http.ErrServerClosed
+	}
+	e.servers = append(e.servers, srv)
+	e.listeners = append(e.listeners, serveListener{addr: srv.Addr, l: l})
+	e.serverLock.Unlock()
+
+	e.startOnce.Do(func() {
+		for _, hook := range e.onStart {
+			hook()
+		}
+		notifyReady()
+	})
+	var err error
+	if certFile != "" || keyFile != "" || srv.TLSConfig != nil {
+		err = srv.ServeTLS(l, certFile, keyFile)
+	} else {
+		err = srv.Serve(l)
+	}
+	if err == http.ErrServerClosed {
+		<-e.done
+		return nil
+	}
+	return err
+}
+
+//OnStart 第一个服务开始监听后执行
+func (e *Engine) OnStart(hooks ...func()) {
+	e.onStart = append(e.onStart, hooks...)
+}
+
+//OnShutdown 所有服务关闭、请求处理完成后执行，可用于释放协程池、关闭日志文件
+func (e *Engine) OnShutdown(hooks ...func()) {
+	e.onShutdown = append(e.onShutdown, hooks...)
+}
+
+//Shutdown 停止接收新请求，等待处理中的请求完成，ctx 超时后强制返回
+func (e *Engine) Shutdown(ctx context.Context) error {
+	var err error
+	e.shutdownOnce.Do(func() {
+		e.serverLock.Lock()
+		e.closed = true
+		servers := e.servers
+		e.serverLock.Unlock()
+		for _, srv := range servers {
+			if shutdownErr := srv.Shutdown(ctx); shutdownErr != nil && err == nil {
+				err = shutdownErr
+			}
+		}
+		for _, hook := range e.onShutdown {
+			hook()
+		}
+		close(e.done)
+	})
+	return err
+}
+
+//ShutdownOnSignal 收到信号后调用 Shutdown，默认监听 SIGINT SIGTERM
+func (e *Engine) ShutdownOnSignal(sig ...os.Signal) {
+	if len(sig) == 0 {
+		sig = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sig...)
+	go func() {
+		s := <-ch
+		signal.Stop(ch)
+		debugPrint("Received signal %v, shutting down\n", s)
+		ctx, cancel := e.shutdownContext()
+		defer cancel()
+		if err := e.Shutdown(ctx); err != nil && e.Logger != nil {
+			e.Logger.Error(err)
+		}
+	}()
+}
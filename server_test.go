@@ -0,0 +1,393 @@
+package cob
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	envTestRestartAddr = "COB_TEST_RESTART_ADDR"
+	envTestSystemd     = "COB_TEST_SYSTEMD"
+)
+
+func TestMain(m *testing.M) {
+	//热重启测试启动的新进程
+	if addr := os.Getenv(envTestRestartAddr); addr != "" {
+		engine := New()
+		engine.Get("/who", func(ctx *Context) {
+			ctx.String(http.StatusOK, "child")
+		})
+		engine.Get("/quit", func(ctx *Context) {
+			ctx.String(http.StatusOK, "bye")
+			go engine.Shutdown(context.Background())
+		})
+		if err := engine.Run(addr); err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	//systemd socket 激活测试启动的新进程
+	if os.Getenv(envTestSystemd) != "" {
+		engine := New()
+		engine.Get("/who", func(ctx *Context) {
+			ctx.String(http.StatusOK, "systemd")
+		})
+		engine.Get("/pid", func(ctx *Context) {
+			ctx.String(http.StatusOK, "%d %s", os.Getpid(), os.Getenv("LISTEN_ADDR"))
+		})
+		engine.Get("/restart", func(ctx *Context) {
+			ctx.String(http.StatusOK, "restarting")
+			go engine.Restart()
+		})
+		engine.Get("/quit", func(ctx *Context) {
+			ctx.String(http.StatusOK, "bye")
+			go engine.Shutdown(context.Background())
+		})
+		if err := engine.RunSystemd(); err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestEngineShutdown(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	engine := New()
+	started := make(chan struct{})
+	handling := make(chan struct{})
+	shutdownHook := false
+	engine.OnStart(func() {
+		close(started)
+	})
+	engine.OnShutdown(func() {
+		shutdownHook = true
+	})
+	engine.Get("/slow", func(ctx *Context) {
+		close(handling)
+		time.Sleep(100 * time.Millisecond)
+		ctx.String(http.StatusOK, "done")
+	})
+
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- engine.Run(addr)
+	}()
+	<-started
+
+	respBody := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/slow")
+		if err != nil {
+			respBody <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		respBody <- string(body)
+	}()
+	<-handling
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := engine.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() = %v", err)
+	}
+	if body := <-respBody; body != "done" {
+		t.Errorf("in-flight request body = %q, want %q", body, "done")
+	}
+	if err := <-runErr; err != nil {
+		t.Errorf("Run() = %v, want nil", err)
+	}
+	if !shutdownHook {
+		t.Errorf("OnShutdown hook not called")
+	}
+	if _, err := http.Get("http://" + addr + "/slow"); err == nil {
+		t.Errorf("request after Shutdown succeeded")
+	}
+}
+
+func TestEngineRunListenerAndUnix(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix socket is not supported")
+	}
+	engine := New()
+	engine.Get("/ping", func(ctx *Context) {
+		ctx.String(http.StatusOK, "pong")
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "cob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sock := filepath.Join(dir, "cob.sock")
+
+	runErr := make(chan error, 2)
+	go func() {
+		runErr <- engine.RunListener(l)
+	}()
+	go func() {
+		runErr <- engine.RunUnix(sock)
+	}()
+
+	unixClient := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", sock)
+		},
+	}}
+	for _, tt := range []struct {
+		client *http.Client
+		url    string
+	}{
+		{http.DefaultClient, "http://" + l.Addr().String() + "/ping"},
+		{unixClient, "http://unix/ping"},
+	} {
+		var body []byte
+		for i := 0; i < 50; i++ {
+			resp, err := tt.client.Get(tt.url)
+			if err == nil {
+				body, _ = ioutil.ReadAll(resp.Body)
+				resp.Body.Close()
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if string(body) != "pong" {
+			t.Errorf("GET %s body = %q, want %q", tt.url, body, "pong")
+		}
+	}
+
+	if err := engine.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() = %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-runErr; err != nil {
+			t.Errorf("run = %v, want nil", err)
+		}
+	}
+	if err := engine.RunListener(l); err != http.ErrServerClosed {
+		t.Errorf("RunListener after Shutdown = %v, want %v", err, http.ErrServerClosed)
+	}
+}
+
+func TestEngineRestart(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("listener handoff is not supported")
+	}
+	addr := freeAddr(t)
+	engine := New()
+	started := make(chan struct{})
+	handling := make(chan struct{})
+	engine.OnStart(func() {
+		close(started)
+	})
+	engine.Get("/who", func(ctx *Context) {
+		ctx.String(http.StatusOK, "parent")
+	})
+	engine.Get("/slow", func(ctx *Context) {
+		close(handling)
+		time.Sleep(100 * time.Millisecond)
+		ctx.String(http.StatusOK, "done")
+	})
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- engine.Run(addr)
+	}()
+	<-started
+
+	get := func(path string) string {
+		resp, err := http.Get("http://" + addr + path)
+		if err != nil {
+			return err.Error()
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return string(body)
+	}
+	respBody := make(chan string, 1)
+	go func() {
+		respBody <- get("/slow")
+	}()
+	<-handling
+
+	os.Setenv(envTestRestartAddr, addr)
+	defer os.Unsetenv(envTestRestartAddr)
+	if err := engine.Restart(); err != nil {
+		t.Fatalf("Restart() = %v", err)
+	}
+	if body := <-respBody; body != "done" {
+		t.Errorf("in-flight request body = %q, want %q", body, "done")
+	}
+	if err := <-runErr; err != nil {
+		t.Errorf("Run() = %v, want nil", err)
+	}
+	if body := get("/who"); body != "child" {
+		t.Errorf("GET /who after Restart = %q, want %q", body, "child")
+	}
+	get("/quit")
+}
+
+func TestEngineRunSystemd(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("socket activation is not supported")
+	}
+	var addrs []string
+	var files []*os.File
+	for i := 0; i < 2; i++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		file, err := l.(*net.TCPListener).File()
+		l.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		addrs = append(addrs, l.Addr().String())
+		files = append(files, file)
+	}
+
+	//与 systemd 相同，LISTEN_PID 为服务进程自身的 pid，listener 从 fd 3 开始
+	cmd := exec.Command("/bin/sh", "-c", `LISTEN_PID=$$ exec "$0"`, os.Args[0])
+	cmd.Env = append(os.Environ(), envTestSystemd+"=1", "LISTEN_FDS=2", "LISTEN_FDNAMES=http:admin",
+		"LISTEN_ADDR=keep")
+	cmd.ExtraFiles = files
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(addr, path string) string {
+		resp, err := http.Get("http://" + addr + path)
+		if err != nil {
+			return err.Error()
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return string(body)
+	}
+	for _, addr := range addrs {
+		var body string
+		for i := 0; i < 100; i++ {
+			if body = get(addr, "/who"); body == "systemd" {
+				break
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		if body != "systemd" {
+			t.Errorf("GET %s/who = %q, want %q", addr, body, "systemd")
+		}
+	}
+	//热重启后新进程继续在 systemd 传入的 listener 上服务，旧进程正常退出
+	first := get(addrs[0], "/pid")
+	get(addrs[0], "/restart")
+	wait := func(name string) {
+		done := make(chan error, 1)
+		go func() {
+			done <- cmd.Wait()
+		}()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("%s exited with %v", name, err)
+			}
+		case <-time.After(5 * time.Second):
+			cmd.Process.Kill()
+			t.Fatalf("%s did not return after Shutdown", name)
+		}
+	}
+	wait("RunSystemd before Restart")
+	for _, addr := range addrs {
+		if pid := get(addr, "/pid"); pid == first || !strings.HasSuffix(pid, " keep") {
+			t.Errorf("GET %s/pid after Restart = %q, want new pid with LISTEN_ADDR=keep (before %q)", addr, pid, first)
+		}
+	}
+	//一次 Shutdown 关闭所有 listener 上的服务
+	get(addrs[0], "/quit")
+}
+
+//lockedBuffer 可以被多个服务协程同时写入
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestEngineRunAllPrintRoutesOnce(t *testing.T) {
+	out := &lockedBuffer{}
+	defaultWriter := DefaultWriter
+	DefaultWriter = out
+	defer func() {
+		DefaultWriter = defaultWriter
+	}()
+	engine := New()
+	engine.Get("/ping", func(ctx *Context) {})
+	var runs []func() error
+	var addrs []string
+	for i := 0; i < 2; i++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		addrs = append(addrs, l.Addr().String())
+		runs = append(runs, func() error {
+			return engine.RunListener(l)
+		})
+	}
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- engine.runAll(runs...)
+	}()
+	for _, addr := range addrs {
+		for i := 0; i < 50; i++ {
+			resp, err := http.Get("http://" + addr + "/ping")
+			if err == nil {
+				resp.Body.Close()
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	if err := engine.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() = %v", err)
+	}
+	if err := <-runErr; err != nil {
+		t.Errorf("runAll() = %v, want nil", err)
+	}
+	if n := strings.Count(out.String(), "/ping"); n != 1 {
+		t.Errorf("route table printed %d times, want 1", n)
+	}
+}
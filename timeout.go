@@ -0,0 +1,125 @@
+package cob
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//Timeout 路由超时中间件，超时后取消 handler 的 Context 并返回503
+//user.Get("/report", handler, cob.Timeout(3*time.Second))
+func Timeout(timeout time.Duration) MiddlewareFunc {
+	return TimeoutWithHandler(timeout, defaultTimeoutHandler)
+}
+
+//TimeoutWithHandler 超时后由 handler 写入响应
+func TimeoutWithHandler(timeout time.Duration, handler HandleFunc) MiddlewareFunc {
+	return func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			timeoutCtx, cancel := context.WithTimeout(ctx.Request.Context(), timeout)
+			defer cancel()
+
+			//后续处理在新的协程中使用副本执行，超时后副本被丢弃，不会放回池中。
+			//请求体仍然有效，表单由副本按需解析，不影响 StreamUploads
+			buf := &timeoutBuffer{header: make(http.Header)}
+			cp := ctx.clone()
+			cp.Request = ctx.Request.WithContext(timeoutCtx)
+			cp.writermem.reset(buf)
+			cp.Writer = &cp.writermem
+			cp.handlers = ctx.handlers
+			cp.index = ctx.index
+
+			done := make(chan struct{})
+			panicChan := make(chan interface{}, 1)
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panicChan <- p
+					}
+				}()
+				next(cp)
+				close(done)
+			}()
+
+			select {
+			case p := <-panicChan:
+				//交给外层的 Recovery 处理
+				panic(p)
+			case <-done:
+				ctx.index = cp.index
+				ctx.StatusCode = cp.StatusCode
+				ctx.syncParsed(cp)
+				ctx.mu.Lock()
+				ctx.Keys = cp.Keys
+				ctx.mu.Unlock()
+				buf.writeTo(ctx.Writer)
+			case <-timeoutCtx.Done():
+				buf.timeout()
+				ctx.Abort()
+				handler(ctx)
+			}
+		}
+	}
+}
+
+//syncParsed 同步副本解析的查询参数和表单，multipart 临时文件由 http.Server 在请求结束后清理
+func (c *Context) syncParsed(cp *Context) {
+	c.queryCache, c.queryErr = cp.queryCache, cp.queryErr
+	c.formCache, c.formErr = cp.formCache, cp.formErr
+	c.Request.Form = cp.Request.Form
+	c.Request.PostForm = cp.Request.PostForm
+	c.Request.MultipartForm = cp.Request.MultipartForm
+}
+
+func defaultTimeoutHandler(ctx *Context) {
+	ctx.String(http.StatusServiceUnavailable, http.StatusText(http.StatusServiceUnavailable))
+}
+
+//timeoutBuffer 缓存 handler 的响应，完成后写入原 ResponseWriter，超时后丢弃
+type timeoutBuffer struct {
+	mu       sync.Mutex
+	header   http.Header
+	code     int
+	body     bytes.Buffer
+	timedOut bool
+}
+
+func (b *timeoutBuffer) Header() http.Header {
+	return b.header
+}
+
+func (b *timeoutBuffer) Write(data []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	return b.body.Write(data)
+}
+
+func (b *timeoutBuffer) WriteHeader(code int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.timedOut {
+		b.code = code
+	}
+}
+
+func (b *timeoutBuffer) timeout() {
+	b.mu.Lock()
+	b.timedOut = true
+	b.mu.Unlock()
+}
+
+func (b *timeoutBuffer) writeTo(w ResponseWriter) {
+	header := w.Header()
+	for k, v := range b.header {
+		header[k] = v
+	}
+	if b.code != 0 {
+		w.WriteHeader(b.code)
+	}
+	w.Write(b.body.Bytes())
+}
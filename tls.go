@@ -0,0 +1,137 @@
+package cob
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+//RunTLSConfig 使用内存中的 tls.Config 提供 HTTPS 服务，
+//配合 CertReloader 可以在证书文件更新后自动加载，设置 ClientAuth 开启双向认证
+func (e *Engine) RunTLSConfig(addr string, config *tls.Config) error {
+	e.debugPrintRoutes()
+	debugPrint("Listening and serving HTTPS on %s\n", addr)
+	if addr == "" {
+		addr = ":https"
+	}
+	l, err := listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	srv := e.newServer(addr)
+	srv.TLSConfig = config
+	return e.serve(srv, l, "", "")
+}
+
+//CertReloader 定期检查证书文件，修改后重新加载，用于 tls.Config.GetCertificate
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	modTime  time.Time
+	stop     chan struct{}
+	once     sync.Once
+}
+
+//NewCertReloader 加载证书并每隔 interval 检查文件是否变化，interval 为0时不自动检查
+func NewCertReloader(certFile, keyFile string, interval time.Duration) (*CertReloader, error) {
+	r := &CertReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		stop:     make(chan struct{}),
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	if interval > 0 {
+		go r.watch(interval)
+	}
+	return r, nil
+}
+
+//Reload 重新加载证书，加载失败时继续使用原证书
+func (r *CertReloader) Reload() error {
+	modTime, err := r.lastModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.cert = &cert
+	r.modTime = modTime
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *CertReloader) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			modTime, err := r.lastModTime()
+			if err != nil {
+				continue
+			}
+			r.mu.RLock()
+			changed := !modTime.Equal(r.modTime)
+			r.mu.RUnlock()
+			if changed {
+				r.Reload()
+			}
+		}
+	}
+}
+
+func (r *CertReloader) lastModTime() (time.Time, error) {
+	certStat, err := os.Stat(r.certFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+	keyStat, err := os.Stat(r.keyFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if keyStat.ModTime().After(certStat.ModTime()) {
+		return keyStat.ModTime(), nil
+	}
+	return certStat.ModTime(), nil
+}
+
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+//Close 停止检查证书文件
+func (r *CertReloader) Close() {
+	r.once.Do(func() {
+		close(r.stop)
+	})
+}
+
+//ClientCAPool 加载用于校验客户端证书的CA
+func ClientCAPool(caFiles ...string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	for _, caFile := range caFiles {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificates found in " + caFile)
+		}
+	}
+	return pool, nil
+}
@@ -0,0 +1,248 @@
+package cob
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func newTestCert(t *testing.T, cn string, serial int64, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	signer, signerKey := tpl, key
+	if parent == nil {
+		tpl.IsCA = true
+		tpl.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func freeAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func TestCertReloaderWatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ca := newTestCert(t, "cob ca", 1, nil)
+	oldCert := newTestCert(t, "old", 2, ca)
+	newCert := newTestCert(t, "new", 3, ca)
+	certFile := filepath.Join(dir, "server.pem")
+	keyFile := filepath.Join(dir, "server.key")
+	ioutil.WriteFile(certFile, oldCert.certPEM, 0600)
+	ioutil.WriteFile(keyFile, oldCert.keyPEM, 0600)
+
+	reloader, err := NewCertReloader(certFile, keyFile, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reloader.Close()
+	commonName := func() string {
+		cert, _ := reloader.GetCertificate(nil)
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return leaf.Subject.CommonName
+	}
+	if cn := commonName(); cn != "old" {
+		t.Fatalf("GetCertificate CN = %q, want %q", cn, "old")
+	}
+
+	ioutil.WriteFile(certFile, newCert.certPEM, 0600)
+	ioutil.WriteFile(keyFile, newCert.keyPEM, 0600)
+	//文件系统的修改时间精度可能较低，确保修改时间晚于首次加载
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+	os.Chtimes(keyFile, later, later)
+	cn := ""
+	for i := 0; i < 100; i++ {
+		if cn = commonName(); cn == "new" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if cn != "new" {
+		t.Errorf("GetCertificate CN after rewrite = %q, want %q", cn, "new")
+	}
+
+	//恢复修改时间更早的备份证书同样会重新加载
+	ioutil.WriteFile(certFile, oldCert.certPEM, 0600)
+	ioutil.WriteFile(keyFile, oldCert.keyPEM, 0600)
+	earlier := time.Now().Add(-time.Hour)
+	os.Chtimes(certFile, earlier, earlier)
+	os.Chtimes(keyFile, earlier, earlier)
+	for i := 0; i < 100; i++ {
+		if cn = commonName(); cn == "old" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if cn != "old" {
+		t.Errorf("GetCertificate CN after restoring backup = %q, want %q", cn, "old")
+	}
+}
+
+func TestEngineMutualTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ca := newTestCert(t, "cob ca", 1, nil)
+	server := newTestCert(t, "server", 2, ca)
+	client := newTestCert(t, "order-service", 3, ca)
+	caFile := filepath.Join(dir, "ca.pem")
+	certFile := filepath.Join(dir, "server.pem")
+	keyFile := filepath.Join(dir, "server.key")
+	ioutil.WriteFile(caFile, ca.certPEM, 0600)
+	ioutil.WriteFile(certFile, server.certPEM, 0600)
+	ioutil.WriteFile(keyFile, server.keyPEM, 0600)
+
+	reloader, err := NewCertReloader(certFile, keyFile, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reloader.Close()
+	clientCAs, err := ClientCAPool(caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	engine := New()
+	engine.Get("/whoami", func(ctx *Context) {
+		ctx.String(http.StatusOK, ctx.ClientIdentity())
+	})
+	addr := freeAddr(t)
+	started := make(chan struct{})
+	engine.OnStart(func() {
+		close(started)
+	})
+	go engine.RunTLSConfig(addr, &tls.Config{
+		GetCertificate: reloader.GetCertificate,
+		ClientAuth:     tls.RequireAndVerifyClientCert,
+		ClientCAs:      clientCAs,
+	})
+	defer engine.Shutdown(context.Background())
+	<-started
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	clientCert, err := tls.X509KeyPair(client.certPEM, client.keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      roots,
+		Certificates: []tls.Certificate{clientCert},
+	}}}
+	resp, err := httpClient.Get("https://" + addr + "/whoami")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "order-service" {
+		t.Errorf("ClientIdentity() = %q, want %q", body, "order-service")
+	}
+
+	//更新证书文件后重新加载
+	renewed := newTestCert(t, "server", 4, ca)
+	ioutil.WriteFile(certFile, renewed.certPEM, 0600)
+	ioutil.WriteFile(keyFile, renewed.keyPEM, 0600)
+	if err := reloader.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := reloader.GetCertificate(nil)
+	leaf, _ := x509.ParseCertificate(cert.Certificate[0])
+	if leaf.SerialNumber.Int64() != 4 {
+		t.Errorf("reloaded certificate serial = %v, want 4", leaf.SerialNumber)
+	}
+}
+
+func TestEngineH2C(t *testing.T) {
+	engine := New()
+	engine.UseH2C = true
+	engine.Get("/proto", func(ctx *Context) {
+		ctx.String(http.StatusOK, ctx.Request.Proto)
+	})
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go engine.RunListener(l)
+	defer engine.Shutdown(context.Background())
+
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+	resp, err := client.Get("http://" + l.Addr().String() + "/proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "HTTP/2.0" {
+		t.Errorf("proto = %q, want %q", body, "HTTP/2.0")
+	}
+}
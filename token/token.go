@@ -245,8 +245,9 @@ func (j *JwtHandler) AuthInterceptor(next cob.HandleFunc) cob.HandleFunc {
 
 func (j *JwtHandler) AuthErrorHandler(ctx *cob.Context, err error) {
 	if j.AuthHandler == nil {
-		ctx.Writer.WriteHeader(http.StatusUnauthorized)
+		ctx.AbortWithStatus(http.StatusUnauthorized)
 	} else {
+		ctx.Abort()
 		j.AuthHandler(ctx, err)
 	}
 }
@@ -1,79 +1,381 @@
 package cob
 
 import (
+	"fmt"
+	"regexp"
 	"strings"
 )
 
+type nodeType uint8
+
+const (
+	static   nodeType = iota //静态节点
+	param                    //参数节点 :id :id<int> {id:[0-9]+}
+	wildcard                 //单段通配 *
+	catchAll                 //多段通配 ** 或 *path，只能出现在路由末尾
+)
+
+//压缩前缀树(radix tree)
+//匹配优先级: 静态 > 参数 > 单段通配 > 多段通配，匹配失败时回溯
 type treeNode struct {
-	name       string
-	children   []*treeNode
-	routerName string
-	isEnd      bool
+	name          string //静态节点为压缩后的前缀，参数节点为 :id，通配节点为 * ** *path
+	nType         nodeType
+	children      []*treeNode //静态子节点
+	indices       string      //静态子节点首字符，与children一一对应
+	paramChildren []*treeNode //参数子节点，有约束的在前
+	wildChild     *treeNode
+	catchAllChild *treeNode
+	key           string            //参数名
+	constraint    string            //参数约束 int 或正则
+	check         func(string) bool //参数约束校验，为nil表示不校验
+	routerName    string            //完整路由，isEnd 时有效
+	isEnd         bool
 }
 
-//put path:/user/name/:id
+//路由参数 /get/:id
+type Param struct {
+	Key   string
+	Value string
+}
+
+type Params []Param
 
+func (ps Params) Get(key string) (string, bool) {
+	for _, p := range ps {
+		if p.Key == key {
+			return p.Value, true
+		}
+	}
+	return "", false
+}
+
+func (ps Params) ByName(key string) string {
+	val, _ := ps.Get(key)
+	return val
+}
+
+//put path:/user/name/:id
 func (t *treeNode) Put(path string) {
-	root := t
-	strs := strings.Split(path, "/")
-	for index, name := range strs {
-		if index != 0 {
-			isMatch := false
-			for _, node := range t.children {
-				if node.name == name {
-					isMatch = true
-					t = node
-					break
+	if path == "" || path[0] != '/' {
+		panic(fmt.Sprintf("path must begin with '/' in path '%s'", path))
+	}
+	node := t
+	rest := path
+	for rest != "" {
+		i := wildcardIndex(rest)
+		if i < 0 {
+			node = node.putStatic(rest)
+			break
+		}
+		if i > 0 {
+			node = node.putStatic(rest[:i])
+		}
+		seg := rest[i : i+wildcardLen(rest[i:], path)]
+		rest = rest[i+len(seg):]
+		node = node.putWild(seg, path, rest == "")
+	}
+	//:id 与 {id} 落在同一节点，路由名不同会导致处理函数互相覆盖
+	if node.isEnd && node.routerName != path {
+		panic(fmt.Sprintf("path '%s' conflicts with existing route '%s'", path, node.routerName))
+	}
+	node.isEnd = true
+	node.routerName = path
+}
+
+//wildcardIndex 返回第一个以 : * { 开头的路径段的位置
+func wildcardIndex(path string) int {
+	for i := 1; i < len(path); i++ {
+		if path[i-1] == '/' && (path[i] == ':' || path[i] == '*' || path[i] == '{') {
+			return i
+		}
+	}
+	return -1
+}
+
+//wildcardLen 返回通配段的长度，{} 中的正则可以包含 { } /
+func wildcardLen(seg, path string) int {
+	if seg[0] != '{' {
+		if end := strings.IndexByte(seg, '/'); end >= 0 {
+			return end
+		}
+		return len(seg)
+	}
+	depth := 0
+	for i := 0; i < len(seg); i++ {
+		switch seg[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				if i+1 < len(seg) && seg[i+1] != '/' {
+					panic(fmt.Sprintf("wildcard '%s' must occupy a whole segment in path '%s'", seg[:i+1], path))
 				}
+				return i + 1
 			}
-			if !isMatch {
-				isEnd := false
-				if index == len(strs)-1 {
-					isEnd = true
-				}
-				node := &treeNode{
-					name:     name,
-					children: make([]*treeNode, 0),
-					isEnd:    isEnd,
-				}
-				t.children = append(t.children, node)
-				t = node
+		}
+	}
+	panic(fmt.Sprintf("unclosed '{' in path '%s'", path))
+}
+
+//参数类型约束 :id<int>
+var paramTypes = map[string]func(string) bool{
+	"int": func(s string) bool {
+		if s != "" && s[0] == '-' {
+			s = s[1:]
+		}
+		return isDigits(s)
+	},
+	"uint": isDigits,
+	"alpha": func(s string) bool {
+		for i := 0; i < len(s); i++ {
+			if !isAlpha(s[i]) {
+				return false
+			}
+		}
+		return s != ""
+	},
+	"alnum": func(s string) bool {
+		for i := 0; i < len(s); i++ {
+			if !isAlpha(s[i]) && !isDigit(s[i]) {
+				return false
+			}
+		}
+		return s != ""
+	},
+	"uuid": func(s string) bool {
+		_, err := ParseUUID(s)
+		return err == nil
+	},
+}
+
+//parseParam 解析参数段 :id :id<int> {id} {id:[0-9]+}，返回参数名、约束和校验函数
+func parseParam(seg, path string) (string, string, func(string) bool) {
+	var key, constraint string
+	var check func(string) bool
+	if seg[0] == '{' {
+		key = seg[1 : len(seg)-1]
+		if i := strings.IndexByte(key, ':'); i >= 0 {
+			key, constraint = key[:i], key[i+1:]
+			re, err := regexp.Compile("^(?:" + constraint + ")$")
+			if err != nil {
+				panic(fmt.Sprintf("invalid constraint '%s' in path '%s': %v", seg, path, err))
+			}
+			check = re.MatchString
+		}
+	} else {
+		key = seg[1:]
+		if i := strings.IndexByte(key, '<'); i >= 0 {
+			if key[len(key)-1] != '>' {
+				panic(fmt.Sprintf("invalid wildcard '%s' in path '%s'", seg, path))
+			}
+			key, constraint = key[:i], key[i+1:len(key)-1]
+			var ok bool
+			if check, ok = paramTypes[constraint]; !ok {
+				panic(fmt.Sprintf("unknown param type '%s' in path '%s'", constraint, path))
+			}
+		}
+	}
+	if key == "" || strings.ContainsAny(key, ":*{}<>") {
+		panic(fmt.Sprintf("invalid wildcard '%s' in path '%s'", seg, path))
+	}
+	return key, constraint, check
+}
+
+func (t *treeNode) putStatic(path string) *treeNode {
+	for {
+		i := strings.IndexByte(t.indices, path[0])
+		if i < 0 {
+			child := &treeNode{name: path, nType: static}
+			t.indices += string(path[0])
+			t.children = append(t.children, child)
+			return child
+		}
+		child := t.children[i]
+		l := commonPrefix(path, child.name)
+		if l < len(child.name) {
+			//分裂节点，原节点保留公共前缀
+			rest := *child
+			rest.name = child.name[l:]
+			*child = treeNode{
+				name:     child.name[:l],
+				nType:    static,
+				children: []*treeNode{&rest},
+				indices:  string(rest.name[0]),
+			}
+		}
+		if l == len(path) {
+			return child
+		}
+		t = child
+		path = path[l:]
+	}
+}
+
+func (t *treeNode) putWild(seg, path string, last bool) *treeNode {
+	switch {
+	case seg[0] == ':' || seg[0] == '{':
+		key, constraint, check := parseParam(seg, path)
+		for _, child := range t.paramChildren {
+			if child.constraint != constraint {
+				continue
+			}
+			if child.key != key {
+				panic(fmt.Sprintf("wildcard '%s' in path '%s' conflicts with existing wildcard '%s'",
+					seg, path, child.name))
 			}
+			return child
 		}
+		child := &treeNode{name: seg, nType: param, key: key, constraint: constraint, check: check}
+		//有约束的参数优先匹配
+		i := len(t.paramChildren)
+		if constraint != "" {
+			for i > 0 && t.paramChildren[i-1].constraint == "" {
+				i--
+			}
+		}
+		t.paramChildren = append(t.paramChildren, nil)
+		copy(t.paramChildren[i+1:], t.paramChildren[i:])
+		t.paramChildren[i] = child
+		return child
+	case seg == "*":
+		if t.wildChild == nil {
+			t.wildChild = &treeNode{name: seg, nType: wildcard}
+		}
+		return t.wildChild
+	default:
+		//多段通配 ** 或 *path
+		if !last {
+			panic(fmt.Sprintf("catch-all '%s' is only allowed at the end of path '%s'", seg, path))
+		}
+		if strings.ContainsAny(seg[1:], ":") || (seg != "**" && strings.ContainsAny(seg[1:], "*")) {
+			panic(fmt.Sprintf("invalid wildcard '%s' in path '%s'", seg, path))
+		}
+		if t.catchAllChild == nil {
+			t.catchAllChild = &treeNode{name: seg, nType: catchAll}
+		} else if t.catchAllChild.name != seg {
+			panic(fmt.Sprintf("catch-all '%s' in path '%s' conflicts with existing catch-all '%s'",
+				seg, path, t.catchAllChild.name))
+		}
+		return t.catchAllChild
 	}
-	t = root
+}
+
+func commonPrefix(a, b string) int {
+	max := len(a)
+	if len(b) < max {
+		max = len(b)
+	}
+	i := 0
+	for i < max && a[i] == b[i] {
+		i++
+	}
+	return i
 }
 
 //get path:/user/name/1
-func (t *treeNode) Get(path string) *treeNode {
-	strs := strings.Split(path, "/")
-	routerName := ""
-	for index, name := range strs {
-		if index != 0 {
-			//isMatch := false
-			for _, node := range t.children {
-				if node.name == name || strings.Contains(node.name, ":") || node.name == "*" {
-					routerName += "/" + node.name
-					node.routerName = routerName
-					//isMatch = true
-					t = node
-					if index == len(strs)-1 {
-						return node
-					}
-					break
-				}
+func (t *treeNode) Get(path string) (*treeNode, Params) {
+	return t.find(path, nil)
+}
+
+//find 将参数追加到 params 中，复用其底层数组避免每次请求分配
+func (t *treeNode) find(path string, params Params) (*treeNode, Params) {
+	params = params[:0]
+	node := t.match(path, &params)
+	if node == nil {
+		return nil, params[:0]
+	}
+	return node, params
+}
+
+func (t *treeNode) match(path string, params *Params) *treeNode {
+	if path == "" {
+		if t.isEnd {
+			return t
+		}
+		return t.matchCatchAll(path, params)
+	}
+	//静态节点优先
+	if i := strings.IndexByte(t.indices, path[0]); i >= 0 {
+		child := t.children[i]
+		if strings.HasPrefix(path, child.name) {
+			if node := child.match(path[len(child.name):], params); node != nil {
+				return node
+			}
+		}
+	}
+	end := strings.IndexByte(path, '/')
+	if end < 0 {
+		end = len(path)
+	}
+	if end == 0 {
+		return t.matchCatchAll(path, params)
+	}
+	//其次参数节点
+	for _, child := range t.paramChildren {
+		if child.check != nil && !child.check(path[:end]) {
+			continue
+		}
+		n := len(*params)
+		*params = append(*params, Param{Key: child.key, Value: path[:end]})
+		if node := child.match(path[end:], params); node != nil {
+			return node
+		}
+		*params = (*params)[:n]
+	}
+	//其次单段通配
+	if t.wildChild != nil {
+		if node := t.wildChild.match(path[end:], params); node != nil {
+			return node
+		}
+	}
+	return t.matchCatchAll(path, params)
+}
+
+//多段通配匹配剩余全部路径，/static/*filepath 的参数名为 filepath，** 的参数名为 *
+func (t *treeNode) matchCatchAll(path string, params *Params) *treeNode {
+	if t.catchAllChild == nil {
+		return nil
+	}
+	*params = append(*params, Param{Key: t.catchAllChild.name[1:], Value: path})
+	return t.catchAllChild
+}
+
+//matchFold 忽略大小写匹配，规范路径写入 buf，用于修正请求路径
+func (t *treeNode) matchFold(path string, buf []byte) ([]byte, bool) {
+	if path == "" {
+		return buf, t.isEnd || t.catchAllChild != nil
+	}
+	for _, child := range t.children {
+		if len(path) >= len(child.name) && strings.EqualFold(path[:len(child.name)], child.name) {
+			if fixed, ok := child.matchFold(path[len(child.name):], append(buf, child.name...)); ok {
+				return fixed, true
+			}
+		}
+	}
+	end := strings.IndexByte(path, '/')
+	if end < 0 {
+		end = len(path)
+	}
+	if end > 0 {
+		for _, child := range t.paramChildren {
+			if child.check != nil && !child.check(path[:end]) {
+				continue
 			}
-			//if !isMatch {
-			//	for _, node := range t.children {
-			//		// /user/**
-			//		// /user/namm/fsdf
-			//		// /user/aa/bb
-			//		if node.name == "**" {
-			//			return node
-			//		}
-			//	}
-			//}
-		}
-	}
-	return nil
+			if fixed, ok := child.matchFold(path[end:], append(buf, path[:end]...)); ok {
+				return fixed, true
+			}
+		}
+		if t.wildChild != nil {
+			if fixed, ok := t.wildChild.matchFold(path[end:], append(buf, path[:end]...)); ok {
+				return fixed, true
+			}
+		}
+	}
+	if t.catchAllChild != nil {
+		return append(buf, path...), true
+	}
+	return buf, false
 }
@@ -1,13 +1,12 @@
 package cob
 
 import (
-	"fmt"
 	"testing"
 )
 
 func TestTreeNode(t *testing.T) {
 
-	root := &treeNode{name: "/", children: make([]*treeNode, 0)}
+	root := &treeNode{}
 
 	root.Put("/user/get/:id")
 	root.Put("/user/get/aa")
@@ -15,16 +14,150 @@ func TestTreeNode(t *testing.T) {
 	root.Put("/user/create/hello")
 	root.Put("/order/create/aa")
 
-	node := root.Get("/user/get/1")
-	fmt.Println(node)
+	root.Put("/user/:name/info")
+	root.Put("/user/*/detail")
+	root.Put("/user/*")
 
-	node = root.Get("/user/create/hello")
-	fmt.Println(node)
+	root.Put("/static/*filepath")
+	root.Put("/proxy/**")
+	root.Put("/proxy/health")
 
-	node = root.Get("/order/create/aa")
-	fmt.Println(node)
+	tests := []struct {
+		path       string
+		routerName string
+		params     Params
+	}{
+		{"/user/get/1", "/user/get/:id", Params{{Key: "id", Value: "1"}}},
+		{"/user/get/aa", "/user/get/aa", nil},
+		{"/user/get/aab", "/user/get/:id", Params{{Key: "id", Value: "aab"}}},
+		{"/user/create/hello", "/user/create/hello", nil},
+		{"/order/create/aa", "/order/create/aa", nil},
+		//回溯: create 静态节点匹配失败，回退到参数节点
+		{"/user/create/info", "/user/:name/info", Params{{Key: "name", Value: "create"}}},
+		{"/user/abc/detail", "/user/*/detail", nil},
+		{"/user/abc", "/user/*", nil},
+		{"/static/css/app.css", "/static/*filepath", Params{{Key: "filepath", Value: "css/app.css"}}},
+		{"/static/", "/static/*filepath", Params{{Key: "filepath", Value: ""}}},
+		{"/proxy/a/b/c", "/proxy/**", Params{{Key: "*", Value: "a/b/c"}}},
+		{"/proxy/health", "/proxy/health", nil},
+		{"/proxy/health/x", "/proxy/**", Params{{Key: "*", Value: "health/x"}}},
+		{"/static", "", nil},
+		{"/user/get/1/x", "", nil},
+		{"/order/create", "", nil},
+		{"/", "", nil},
+	}
+	for _, tt := range tests {
+		node, params := root.Get(tt.path)
+		if tt.routerName == "" {
+			if node != nil {
+				t.Errorf("Get(%q) = %q, want no match", tt.path, node.routerName)
+			}
+			continue
+		}
+		if node == nil {
+			t.Errorf("Get(%q) = nil, want %q", tt.path, tt.routerName)
+			continue
+		}
+		if node.routerName != tt.routerName {
+			t.Errorf("Get(%q) = %q, want %q", tt.path, node.routerName, tt.routerName)
+		}
+		if len(params) != len(tt.params) {
+			t.Errorf("Get(%q) params = %v, want %v", tt.path, params, tt.params)
+			continue
+		}
+		for i := range params {
+			if params[i] != tt.params[i] {
+				t.Errorf("Get(%q) params = %v, want %v", tt.path, params, tt.params)
+			}
+		}
+	}
+}
+
+func TestTreeNodeConflict(t *testing.T) {
+	tests := []struct {
+		routes []string
+	}{
+		{[]string{"/user/:id", "/user/:name/info"}},
+		{[]string{"/user/:"}},
+		{[]string{"/user/*name/info"}},
+		{[]string{"/static/*filepath", "/static/*path"}},
+		{[]string{"user"}},
+		{[]string{"/a/:id", "/a/{id}"}},
+		{[]string{"/a/{id:[0-9]+}/b", "/a/{id:[0-9]+}/{b}", "/a/{id:[0-9]+}/:b"}},
+	}
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Put(%v) did not panic", tt.routes)
+				}
+			}()
+			root := &treeNode{}
+			for _, r := range tt.routes {
+				root.Put(r)
+			}
+		}()
+	}
+}
+
+func TestTreeNodeConstraint(t *testing.T) {
+	root := &treeNode{}
+	root.Put("/get/:name")
+	root.Put("/get/:id<int>")
+	root.Put("/get/{code:[a-z]{3}}/info")
+	root.Put("/order/{id:[0-9]+}")
+	root.Put("/file/:id<uuid>")
 
-	node = root.Get("/user/get/aa")
-	fmt.Println(node)
+	tests := []struct {
+		path       string
+		routerName string
+		params     Params
+	}{
+		{"/get/12", "/get/:id<int>", Params{{Key: "id", Value: "12"}}},
+		{"/get/-12", "/get/:id<int>", Params{{Key: "id", Value: "-12"}}},
+		{"/get/abc", "/get/:name", Params{{Key: "name", Value: "abc"}}},
+		{"/get/abc/info", "/get/{code:[a-z]{3}}/info", Params{{Key: "code", Value: "abc"}}},
+		{"/get/abcd/info", "", nil},
+		{"/order/12", "/order/{id:[0-9]+}", Params{{Key: "id", Value: "12"}}},
+		{"/order/12a", "", nil},
+		{"/file/1b4e28ba-2fa1-11d2-883f-0016d3cca427", "/file/:id<uuid>",
+			Params{{Key: "id", Value: "1b4e28ba-2fa1-11d2-883f-0016d3cca427"}}},
+		{"/file/1b4e28ba", "", nil},
+	}
+	for _, tt := range tests {
+		node, params := root.Get(tt.path)
+		if tt.routerName == "" {
+			if node != nil {
+				t.Errorf("Get(%q) = %q, want no match", tt.path, node.routerName)
+			}
+			continue
+		}
+		if node == nil || node.routerName != tt.routerName {
+			t.Errorf("Get(%q) = %v, want %q", tt.path, node, tt.routerName)
+			continue
+		}
+		if len(params) != len(tt.params) || params[0] != tt.params[0] {
+			t.Errorf("Get(%q) params = %v, want %v", tt.path, params, tt.params)
+		}
+	}
 
+	for _, routes := range [][]string{
+		{"/get/:id<int>", "/get/:code<int>"},
+		{"/get/:id<number>"},
+		{"/get/{id:[0-9+}"},
+		{"/get/{id:[0-9]+}x"},
+		{"/get/{id"},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Put(%v) did not panic", routes)
+				}
+			}()
+			root := &treeNode{}
+			for _, r := range routes {
+				root.Put(r)
+			}
+		}()
+	}
 }
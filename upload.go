@@ -0,0 +1,242 @@
+package cob
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	ErrFileTooLarge       = errors.New("upload file too large")
+	ErrUploadTooLarge     = errors.New("upload files exceed total size limit")
+	ErrTooManyFiles       = errors.New("too many upload files")
+	ErrFileTypeNotAllowed = errors.New("upload file type not allowed")
+	ErrUnsafePath         = errors.New("unsafe file path")
+)
+
+//sniffLen http.DetectContentType 最多使用的字节数
+const sniffLen = 512
+
+//UploadConfig 上传限制，为0表示不限制
+type UploadConfig struct {
+	MaxFileSize  int64 //单个文件大小
+	MaxTotalSize int64 //所有文件总大小
+	MaxFiles     int   //文件数量
+	//允许的文件类型，根据文件内容识别，如 image/png image/*，为空时不限制
+	AllowedTypes []string
+	//SaveUploads 保存的文件名，默认为去掉路径后的原文件名
+	FileName func(file *UploadedFile) string
+}
+
+func (config *UploadConfig) allowed(contentType string) bool {
+	if len(config.AllowedTypes) == 0 {
+		return true
+	}
+	for _, allowed := range config.AllowedTypes {
+		if allowed == contentType || allowed == "*/*" {
+			return true
+		}
+		if strings.HasSuffix(allowed, "/*") && strings.HasPrefix(contentType, allowed[:len(allowed)-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+//UploadedFile 流式上传的文件
+type UploadedFile struct {
+	Field       string //表单字段名
+	Filename    string //去掉路径后的文件名
+	ContentType string //根据文件内容识别的类型
+	Size        int64
+	Path        string //SaveUploads 保存的路径
+	Header      textproto.MIMEHeader
+}
+
+//SaveUploadedFile 将 file 以原文件名保存到 dir 目录，返回保存的路径
+func (c *Context) SaveUploadedFile(file *multipart.FileHeader, dir string) (string, error) {
+	name, err := safeFilename(file.Filename)
+	if err != nil {
+		return "", err
+	}
+	dst, err := safeJoin(dir, name)
+	if err != nil {
+		return "", err
+	}
+	return dst, c.TransferTo(file, dst)
+}
+
+//StreamUploads 不经过 ParseMultipartForm，逐个读取上传文件写入 open 返回的 Writer，
+//Writer 实现 io.Closer 时写入完成后关闭。非文件字段可以在之后通过 PostForm 获取。
+//出错时返回已经处理的文件
+func (c *Context) StreamUploads(config UploadConfig, open func(file *UploadedFile) (io.Writer, error)) ([]*UploadedFile, error) {
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	values := url.Values{}
+	maxValueSize := c.maxMultipartMemory()
+	var files []*UploadedFile
+	var total, valueSize int64
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return files, err
+		}
+		if part.FileName() == "" {
+			var buf bytes.Buffer
+			n, err := io.Copy(&buf, io.LimitReader(part, maxValueSize-valueSize+1))
+			part.Close()
+			if err != nil {
+				return files, err
+			}
+			valueSize += n
+			if valueSize > maxValueSize {
+				return files, multipart.ErrMessageTooLarge
+			}
+			values.Add(part.FormName(), buf.String())
+			continue
+		}
+		if config.MaxFiles > 0 && len(files) >= config.MaxFiles {
+			part.Close()
+			return files, ErrTooManyFiles
+		}
+		file, err := streamPart(part, &config, total, open)
+		part.Close()
+		if err != nil {
+			return files, err
+		}
+		total += file.Size
+		files = append(files, file)
+	}
+	if c.formCache == nil {
+		c.formCache = values
+	}
+	return files, nil
+}
+
+//SaveUploads 将上传文件流式保存到 dir 目录，文件名去掉路径，已存在同名文件时返回错误，
+//任意文件出错时删除本次已保存的文件
+func (c *Context) SaveUploads(dir string, config UploadConfig) ([]*UploadedFile, error) {
+	var saved []string
+	files, err := c.StreamUploads(config, func(file *UploadedFile) (io.Writer, error) {
+		name := file.Filename
+		if config.FileName != nil {
+			var err error
+			if name, err = safeFilename(config.FileName(file)); err != nil {
+				return nil, err
+			}
+		}
+		dst, err := safeJoin(dir, name)
+		if err != nil {
+			return nil, err
+		}
+		out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err != nil {
+			return nil, err
+		}
+		saved = append(saved, dst)
+		file.Path = dst
+		return out, nil
+	})
+	if err != nil {
+		for _, dst := range saved {
+			os.Remove(dst)
+		}
+		return nil, err
+	}
+	return files, nil
+}
+
+//streamPart 识别文件类型后写入，total 为之前文件的总大小
+func streamPart(part *multipart.Part, config *UploadConfig, total int64, open func(file *UploadedFile) (io.Writer, error)) (*UploadedFile, error) {
+	name, err := safeFilename(part.FileName())
+	if err != nil {
+		return nil, err
+	}
+	file := &UploadedFile{Field: part.FormName(), Filename: name, Header: part.Header}
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(part, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	head = head[:n]
+	file.ContentType = http.DetectContentType(head)
+	if i := strings.IndexByte(file.ContentType, ';'); i >= 0 {
+		file.ContentType = file.ContentType[:i]
+	}
+	if !config.allowed(file.ContentType) {
+		return nil, fmt.Errorf("%s %s: %w", name, file.ContentType, ErrFileTypeNotAllowed)
+	}
+
+	limited, limit, limitErr := config.MaxFileSize > 0, config.MaxFileSize, ErrFileTooLarge
+	if config.MaxTotalSize > 0 {
+		if remain := config.MaxTotalSize - total; !limited || remain < limit {
+			limited, limit, limitErr = true, remain, ErrUploadTooLarge
+		}
+	}
+	w, err := open(file)
+	if err != nil {
+		return nil, err
+	}
+	var src io.Reader = io.MultiReader(bytes.NewReader(head), part)
+	if limited {
+		src = io.LimitReader(src, limit+1)
+	}
+	file.Size, err = io.Copy(w, src)
+	if closer, ok := w.(io.Closer); ok {
+		if closeErr := closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if limited && file.Size > limit {
+		return nil, fmt.Errorf("%s: %w", name, limitErr)
+	}
+	return file, nil
+}
+
+//safeFilename 去掉客户端文件名中的路径，windows 客户端可能使用 \ 分隔
+func safeFilename(name string) (string, error) {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == ".." || name == "/" || strings.ContainsRune(name, 0) {
+		return "", ErrUnsafePath
+	}
+	return name, nil
+}
+
+//safeJoin 拼接后的路径必须在 dir 目录下
+func safeJoin(dir, name string) (string, error) {
+	dst := filepath.Join(dir, name)
+	rel, err := filepath.Rel(dir, dst)
+	if err != nil || rel == "." || containsDotDot(rel) {
+		return "", ErrUnsafePath
+	}
+	return dst, nil
+}
+
+func containsDotDot(p string) bool {
+	for _, elem := range strings.FieldsFunc(p, func(r rune) bool {
+		return r == '/' || r == '\\'
+	}) {
+		if elem == ".." {
+			return true
+		}
+	}
+	return false
+}
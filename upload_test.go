@@ -0,0 +1,153 @@
+package cob
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n0000000000")
+
+type uploadPart struct {
+	field    string
+	filename string
+	content  []byte
+}
+
+func newUploadRequest(t *testing.T, parts ...uploadPart) *http.Request {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for _, p := range parts {
+		if p.filename == "" {
+			w.WriteField(p.field, string(p.content))
+			continue
+		}
+		fw, err := w.CreateFormFile(p.field, p.filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write(p.content)
+	}
+	w.Close()
+	r := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	r.Header.Set("Content-Type", w.FormDataContentType())
+	return r
+}
+
+func TestContextFormFile(t *testing.T) {
+	ctx := &Context{Request: newUploadRequest(t, uploadPart{"name", "", []byte("cob")})}
+	if _, err := ctx.FormFile("avatar"); err != http.ErrMissingFile {
+		t.Errorf("FormFile(missing) = %v, want %v", err, http.ErrMissingFile)
+	}
+	ctx = &Context{Request: httptest.NewRequest(http.MethodPost, "/upload", nil)}
+	if _, err := ctx.FormFiles("avatar"); err == nil {
+		t.Errorf("FormFiles on non multipart request succeeded")
+	}
+
+	dir, err := ioutil.TempDir("", "cob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ctx = &Context{Request: newUploadRequest(t, uploadPart{"avatar", `..\..\evil.png`, pngHeader})}
+	file, err := ctx.FormFile("avatar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dst, err := ctx.SaveUploadedFile(file, dir); err != nil || dst != filepath.Join(dir, "evil.png") {
+		t.Errorf("SaveUploadedFile() = %q, %v", dst, err)
+	}
+	//TransferTo 按调用方给出的路径保存，不拒绝合法的相对路径
+	dst := dir + "/../" + filepath.Base(dir) + "/copy.png"
+	if err := ctx.TransferTo(file, dst); err != nil {
+		t.Errorf("TransferTo(%q) = %v", dst, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "copy.png")); err != nil {
+		t.Errorf("TransferTo did not write copy.png: %v", err)
+	}
+}
+
+func TestContextSaveUploads(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	config := UploadConfig{MaxFileSize: 64, MaxTotalSize: 100, AllowedTypes: []string{"image/*"}}
+
+	ctx := &Context{Request: newUploadRequest(t,
+		uploadPart{"name", "", []byte("cob")},
+		uploadPart{"avatar", "../../a.png", pngHeader},
+		uploadPart{"avatar", "b.png", pngHeader},
+	)}
+	files, err := ctx.SaveUploads(dir, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[0].Path != filepath.Join(dir, "a.png") || files[0].ContentType != "image/png" ||
+		files[0].Size != int64(len(pngHeader)) {
+		t.Fatalf("SaveUploads() = %+v", files[0])
+	}
+	if ctx.PostForm("name") != "cob" {
+		t.Errorf("PostForm(name) = %q, want cob", ctx.PostForm("name"))
+	}
+
+	large := append(append([]byte{}, pngHeader...), make([]byte, 60)...)
+	for _, tt := range []struct {
+		parts []uploadPart
+		err   error
+	}{
+		{[]uploadPart{{"doc", "c.txt", []byte("plain text")}}, ErrFileTypeNotAllowed},
+		{[]uploadPart{{"avatar", "c.png", large}}, ErrFileTooLarge},
+		{[]uploadPart{{"avatar", "c.png", pngHeader}, {"avatar", "d.png", pngHeader}, {"avatar", "e.png", pngHeader},
+			{"avatar", "f.png", pngHeader}, {"avatar", "g.png", pngHeader}, {"avatar", "h.png", pngHeader}}, ErrUploadTooLarge},
+	} {
+		ctx := &Context{Request: newUploadRequest(t, tt.parts...)}
+		if _, err := ctx.SaveUploads(dir, config); !errors.Is(err, tt.err) {
+			t.Errorf("SaveUploads(%s) = %v, want %v", tt.parts[0].filename, err, tt.err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "c.png")); !os.IsNotExist(err) {
+			t.Errorf("partial upload c.png not removed")
+		}
+	}
+}
+
+func TestContextSaveUploadsTimeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	engine := New()
+	var name string
+	engine.Use(func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			next(ctx)
+			name = ctx.PostForm("name")
+		}
+	})
+	//Timeout 不会提前解析表单，handler 中仍然可以流式读取上传文件
+	engine.Post("/upload", func(ctx *Context) {
+		files, err := ctx.SaveUploads(dir, UploadConfig{AllowedTypes: []string{"image/*"}})
+		ctx.String(http.StatusOK, "files=%d err=%v", len(files), err)
+	}, Timeout(time.Second))
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, newUploadRequest(t,
+		uploadPart{"name", "", []byte("cob")},
+		uploadPart{"avatar", "a.png", pngHeader},
+	))
+	if w.Body.String() != "files=1 err=<nil>" {
+		t.Errorf("POST /upload body = %q, want %q", w.Body.String(), "files=1 err=<nil>")
+	}
+	if name != "cob" {
+		t.Errorf("PostForm(name) after Timeout = %q, want cob", name)
+	}
+}